@@ -0,0 +1,280 @@
+package z21
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidCVRange     = errors.New("z21: invalid CV range")
+	ErrAddressOnMainTrack = errors.New("z21: address CV not restored on the main track")
+)
+
+// CVRange is an inclusive range of CVs. If Page is set, the range must
+// lie within the indexed window and is read from that page.
+type CVRange struct {
+	First uint16
+	Last  uint16
+	Page  *CVPage
+}
+
+// BackupCV is a single CV value saved in a backup.
+type BackupCV struct {
+	CV    uint16  `json:"cv"`
+	Page  *CVPage `json:"page,omitempty"`
+	Value uint8   `json:"value"`
+}
+
+// DecoderBackup holds the CV values read from a decoder.
+type DecoderBackup struct {
+	Created time.Time    `json:"created"`
+	Decoder *DecoderInfo `json:"decoder"`
+	CVs     []BackupCV   `json:"cvs"`
+}
+
+// CVDiff reports a CV that did not verify after a restore.
+type CVDiff struct {
+	CV   uint16
+	Page *CVPage
+	Want uint8
+	Got  uint8
+	Err  error
+}
+
+// RestoreReport summarises a restore.
+type RestoreReport struct {
+	Written int
+	Skipped int
+	Diffs   []CVDiff
+}
+
+// Backup reads the given CV ranges from a decoder together with its
+// identity. CVs the decoder does not answer for are left out.
+func Backup(ctx context.Context, p CVProgrammer, ranges ...CVRange) (*DecoderBackup, error) {
+	for _, r := range ranges {
+		if err := r.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	ip := NewIndexedProgrammer(p)
+	info, err := identifyDecoder(ctx, ip)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &DecoderBackup{
+		Created: time.Now(),
+		Decoder: info,
+	}
+	// Plain ranges go first, so CV31 and CV32 are saved before selecting
+	// a page overwrites them.
+	for _, paged := range []bool{false, true} {
+		for _, r := range ranges {
+			if (r.Page != nil) != paged {
+				continue
+			}
+			for cv := r.First; cv <= r.Last; cv++ {
+				v, err := ip.readCV(ctx, r.Page, cv)
+				if isUnanswered(err) {
+					continue
+				}
+				if err != nil {
+					return nil, err
+				}
+				b.CVs = append(b.CVs, BackupCV{CV: cv, Page: r.Page, Value: v})
+			}
+		}
+	}
+	return b, nil
+}
+
+// Restore writes a backup to a decoder and verifies every CV by reading
+// it back. The read-only CV7 and CV8 are skipped, as writing CV8 resets
+// many decoders to factory defaults.
+//
+// The index CVs 31 and 32 are written after all paged CVs, since
+// selecting each page overwrites them. The address CVs 1, 17, 18 and 29
+// are written last, as they change the
+// address the decoder answers to. On the main track they are not written
+// at all and reported as diffs with ErrAddressOnMainTrack, since the
+// programmer could not reach the decoder afterwards.
+func Restore(ctx context.Context, p CVProgrammer, b *DecoderBackup) (*RestoreReport, error) {
+	ip := NewIndexedProgrammer(p)
+	report := &RestoreReport{}
+
+	var cvs, index, addr []BackupCV
+	for _, c := range b.CVs {
+		switch {
+		case isReadOnlyCV(c):
+			report.Skipped++
+		case isIndexCV(c):
+			index = append(index, c)
+		case isAddressCV(c):
+			addr = append(addr, c)
+		default:
+			cvs = append(cvs, c)
+		}
+	}
+	if onMainTrack(p) {
+		for _, c := range addr {
+			report.Skipped++
+			report.Diffs = append(report.Diffs, CVDiff{CV: c.CV, Want: c.Value, Err: ErrAddressOnMainTrack})
+		}
+		addr = nil
+	}
+	sort.SliceStable(addr, func(i, j int) bool {
+		return addressCVOrder[addr[i].CV] < addressCVOrder[addr[j].CV]
+	})
+
+	for _, group := range [][]BackupCV{cvs, index, addr} {
+		if err := restoreCVs(ctx, ip, group, report); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// Save writes the backup to a JSON file.
+func (b *DecoderBackup) Save(path string) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// LoadBackup reads a backup from a JSON file.
+func LoadBackup(path string) (*DecoderBackup, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	b := &DecoderBackup{}
+	if err := json.Unmarshal(data, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// OK reports whether every restored CV verified.
+func (r *RestoreReport) OK() bool {
+	return len(r.Diffs) == 0
+}
+
+func (r *RestoreReport) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d written, %d skipped, %d mismatched", r.Written, r.Skipped, len(r.Diffs))
+	for _, d := range r.Diffs {
+		sb.WriteString("\n")
+		sb.WriteString(d.String())
+	}
+	return sb.String()
+}
+
+func (d CVDiff) String() string {
+	name := fmt.Sprintf("CV%d", d.CV)
+	if d.Page != nil {
+		name = fmt.Sprintf("CV%d (CV31=%d, CV32=%d)", d.CV, d.Page.CV31, d.Page.CV32)
+	}
+	if d.Err != nil {
+		return fmt.Sprintf("%s: want %d, %v", name, d.Want, d.Err)
+	}
+	return fmt.Sprintf("%s: want %d, got %d", name, d.Want, d.Got)
+}
+
+// ---------- helpers ----------
+
+func (r CVRange) validate() error {
+	if r.First < 1 || r.First > r.Last || r.Last > MaxCV {
+		return ErrInvalidCVRange
+	}
+	if r.Page != nil && (r.First < FirstIndexedCV || r.Last > LastIndexedCV) {
+		return ErrInvalidCVRange
+	}
+	return nil
+}
+
+func (ip *IndexedProgrammer) readCV(ctx context.Context, page *CVPage, cv uint16) (uint8, error) {
+	if page == nil {
+		return ip.ReadCV(ctx, cv)
+	}
+	return ip.ReadIndexed(ctx, *page, cv)
+}
+
+func (ip *IndexedProgrammer) writeBackupCV(ctx context.Context, c BackupCV) error {
+	if c.Page == nil {
+		return ip.WriteCV(ctx, c.CV, c.Value)
+	}
+	return ip.WriteIndexed(ctx, *c.Page, c.CV, c.Value)
+}
+
+// restoreCVs writes the CVs, then verifies them.
+func restoreCVs(ctx context.Context, ip *IndexedProgrammer, cvs []BackupCV, report *RestoreReport) error {
+	for _, c := range cvs {
+		if err := ip.writeBackupCV(ctx, c); err != nil && !errors.Is(err, ErrCvVerify) {
+			return err
+		}
+		report.Written++
+	}
+
+	for _, c := range cvs {
+		v, err := ip.readCV(ctx, c.Page, c.CV)
+		if err != nil || v != c.Value {
+			report.Diffs = append(report.Diffs, CVDiff{
+				CV:   c.CV,
+				Page: c.Page,
+				Want: c.Value,
+				Got:  v,
+				Err:  err,
+			})
+		}
+	}
+	return nil
+}
+
+// addressCVOrder is the order address CVs are restored in: the long
+// address before CV1, and CV29 last to switch between them, as in
+// ProgramAddress.
+var addressCVOrder = map[uint16]int{
+	CV_EXTENDED_ADDRESS_MSB: 0,
+	CV_EXTENDED_ADDRESS_LSB: 1,
+	CV_PRIMARY_ADDRESS:      2,
+	CV_CONFIGURATION:        3,
+}
+
+func isIndexCV(c BackupCV) bool {
+	return c.Page == nil && (c.CV == CV_INDEX_HIGH || c.CV == CV_INDEX_LOW)
+}
+
+func isAddressCV(c BackupCV) bool {
+	_, ok := addressCVOrder[c.CV]
+	return c.Page == nil && ok
+}
+
+// onMainTrack reports whether p programs a decoder on the main track.
+func onMainTrack(p CVProgrammer) bool {
+	switch p := p.(type) {
+	case *PomProgrammer, *sessionPom:
+		return true
+	case *IndexedProgrammer:
+		return onMainTrack(p.CVProgrammer)
+	}
+	return false
+}
+
+func isReadOnlyCV(c BackupCV) bool {
+	return c.Page == nil && (c.CV == CV_VERSION || c.CV == CV_MANUFACTURER)
+}
+
+// isUnanswered reports whether a read failed because the decoder does
+// not implement the CV.
+func isUnanswered(err error) bool {
+	return errors.Is(err, ErrCvNack) || errors.Is(err, ErrNoRailComAnswer)
+}
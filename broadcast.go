@@ -1,6 +1,10 @@
 package z21
 
-import "encoding/binary"
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+)
 
 const (
 	TRACK_UPDATES            uint32 = 0x00000001 // bit 0
@@ -74,3 +78,44 @@ func (m *BroadcastFlags) EncapType() uint16 {
 func (m *BroadcastFlags) Key() (string, bool) {
 	return "", false
 }
+
+// Subscribe adds the given broadcast flags to those the Z21 currently
+// sends to this client.
+func (nc *Conn) Subscribe(ctx context.Context, flags uint32) error {
+	return nc.updateBroadcastFlags(ctx, func(cur uint32) uint32 {
+		return cur | flags
+	})
+}
+
+// Unsubscribe removes the given broadcast flags.
+func (nc *Conn) Unsubscribe(ctx context.Context, flags uint32) error {
+	return nc.updateBroadcastFlags(ctx, func(cur uint32) uint32 {
+		return cur &^ flags
+	})
+}
+
+// ---------- helpers ----------
+
+func (nc *Conn) updateBroadcastFlags(ctx context.Context, update func(uint32) uint32) error {
+	resp, err := nc.SendRcv(ctx, &SubscribedBroadcastFlags{})
+	if err != nil {
+		return err
+	}
+	cur, ok := resp.(*SubscribedBroadcastFlags)
+	if !ok {
+		return fmt.Errorf("z21: unexpected broadcast flags reply %T", resp)
+	}
+	_, err = nc.SendRcv(ctx, &BroadcastFlags{Flags: Mask32(update(uint32(cur.Flags)))})
+	return err
+}
+
+// trackBroadcastFlags follows the flags set for and reported to this
+// client; callers must hold nc.mu.
+func (nc *Conn) trackBroadcastFlags(m Serializable) {
+	switch m := m.(type) {
+	case *BroadcastFlags:
+		nc.sysUpdates = m.Flags.Has(SYSTEM_UPDATES)
+	case *SubscribedBroadcastFlags:
+		nc.sysUpdates = m.Flags.Has(SYSTEM_UPDATES)
+	}
+}
@@ -9,8 +9,18 @@ const (
 )
 
 const (
-	CANMessageTypeOccupancy uint8 = 0x00
-	CANMessageTypeStatus    uint8 = 0x01
+	CANMessageTypeOccupancy    uint8 = 0x00
+	CANMessageTypeStatus       uint8 = 0x01
+	CANMessageTypeRailComFirst uint8 = 0x11
+	CANMessageTypeRailComLast  uint8 = 0x1F
+)
+
+type RailComDirection uint8
+
+const (
+	RailComDirectionUnknown RailComDirection = iota
+	RailComDirectionForward
+	RailComDirectionBackward
 )
 
 // LAN_CAN_DETECTOR
@@ -60,3 +70,125 @@ func (m *CanDetector) EncapType() uint16 {
 func (m *CanDetector) Key() (string, bool) {
 	return "", false
 }
+
+// CanOccupancy is a LAN_CAN_DETECTOR reply of type CANMessageTypeStatus
+// carrying the occupancy state of a port in Value1.
+type CanOccupancy struct {
+	CanDetector
+	Status uint16 `json:"status"`
+}
+
+// ---------- Message interface ----------
+
+func (m *CanOccupancy) String() string {
+	return "can occupancy"
+}
+
+func (m *CanOccupancy) Unpack(data []byte) error {
+	if err := m.CanDetector.Unpack(data); err != nil {
+		return err
+	}
+	m.Status = m.Value1
+	return nil
+}
+
+// Occupied reports whether the port is occupied.
+func (m *CanOccupancy) Occupied() bool {
+	return m.Status&BUSY_NOVOLT != 0
+}
+
+// Powered reports whether the port has track voltage. Only FREE_NOVOLT
+// and BUSY_NOVOLT report none; an overloaded port is still powered.
+func (m *CanOccupancy) Powered() bool {
+	switch m.Status {
+	case FREE_NOVOLT, BUSY_NOVOLT:
+		return false
+	default:
+		return true
+	}
+}
+
+// Overload returns the overload level of the port, 0 if none.
+func (m *CanOccupancy) Overload() uint8 {
+	if m.Status&0xFF00 != BUSY_OVERLOAD1&0xFF00 {
+		return 0
+	}
+	return uint8(m.Status)
+}
+
+// CanRailCom is a LAN_CAN_DETECTOR reply of type 0x11 to 0x1F carrying
+// up to two of the RailCom addresses seen on a port. Type 0x11 holds the
+// first two addresses, 0x12 the next two and so on.
+type CanRailCom struct {
+	CanDetector
+	Locos []RailComLoco `json:"locos"`
+}
+
+type RailComLoco struct {
+	Address   uint16           `json:"address"`
+	Direction RailComDirection `json:"direction"`
+}
+
+// ---------- Message interface ----------
+
+func (m *CanRailCom) String() string {
+	return "can railcom"
+}
+
+func (m *CanRailCom) Unpack(data []byte) error {
+	if err := m.CanDetector.Unpack(data); err != nil {
+		return err
+	}
+	m.Locos = m.Locos[:0]
+	for _, v := range []uint16{m.Value1, m.Value2} {
+		if loco, ok := decodeRailComLoco(v); ok {
+			m.Locos = append(m.Locos, loco)
+		}
+	}
+	return nil
+}
+
+func (d RailComDirection) String() string {
+	switch d {
+	case RailComDirectionForward:
+		return "forward"
+	case RailComDirectionBackward:
+		return "backward"
+	default:
+		return "unknown"
+	}
+}
+
+// ---------- helpers ----------
+
+// decodeCanDetector picks the typed message for a LAN_CAN_DETECTOR
+// frame from its type byte.
+func decodeCanDetector(p []byte) Serializable {
+	if len(p) < 6 {
+		return &CanDetector{}
+	}
+	switch t := p[5]; {
+	case t == CANMessageTypeStatus:
+		return &CanOccupancy{}
+	case t >= CANMessageTypeRailComFirst && t <= CANMessageTypeRailComLast:
+		return &CanRailCom{}
+	default:
+		return &CanDetector{}
+	}
+}
+
+// decodeRailComLoco decodes an address word whose two upper bits hold
+// the direction; address 0 means no loco.
+func decodeRailComLoco(v uint16) (RailComLoco, bool) {
+	loco := RailComLoco{Address: v & 0x3FFF}
+	if loco.Address == 0 {
+		return loco, false
+	}
+	switch v >> 14 {
+	case 0x02:
+		loco.Direction = RailComDirectionForward
+	case 0x03:
+		loco.Direction = RailComDirectionBackward
+	}
+	return loco, true
+}
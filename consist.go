@@ -0,0 +1,100 @@
+package z21
+
+import (
+	"context"
+	"errors"
+)
+
+const (
+	CV19_ADDRESS  uint8 = 0x7F // bits 0-6
+	CV19_REVERSED uint8 = 0x80 // bit 7
+
+	MaxConsistAddress uint8 = 127
+)
+
+var ErrInvalidConsistAddress = errors.New("z21: invalid consist address")
+
+// Consist is the advanced consist configuration stored in CV19. Address
+// 0 means the decoder is not part of a consist; Reversed runs it against
+// the consist's direction.
+type Consist struct {
+	Address  uint8 `json:"address"`
+	Reversed bool  `json:"reversed"`
+}
+
+func DecodeConsist(v uint8) Consist {
+	return Consist{
+		Address:  v & CV19_ADDRESS,
+		Reversed: Mask8(v).Has(CV19_REVERSED),
+	}
+}
+
+func (c Consist) Encode() (uint8, error) {
+	if c.Address > MaxConsistAddress {
+		return 0, ErrInvalidConsistAddress
+	}
+	v := c.Address
+	if c.Reversed {
+		v |= CV19_REVERSED
+	}
+	return v, nil
+}
+
+// InConsist reports whether the decoder is part of a consist.
+func (c Consist) InConsist() bool {
+	return c.Address != 0
+}
+
+// ReadConsist reads the consist configuration of the decoder on the
+// programming track.
+func (nc *Conn) ReadConsist(ctx context.Context) (Consist, error) {
+	var c Consist
+	err := nc.programming(ctx, func(p CVProgrammer) error {
+		var err error
+		c, err = readConsist(ctx, p)
+		return err
+	})
+	return c, err
+}
+
+// WriteConsist writes the consist configuration of the decoder on the
+// programming track. Address 0 removes it from its consist.
+func (nc *Conn) WriteConsist(ctx context.Context, c Consist) error {
+	return nc.programming(ctx, func(p CVProgrammer) error {
+		return writeConsist(ctx, p, c)
+	})
+}
+
+func (s *ProgrammingSession) ReadConsist(ctx context.Context) (Consist, error) {
+	var c Consist
+	err := s.run(func(p CVProgrammer) error {
+		var err error
+		c, err = readConsist(ctx, p)
+		return err
+	})
+	return c, err
+}
+
+func (s *ProgrammingSession) WriteConsist(ctx context.Context, c Consist) error {
+	return s.run(func(p CVProgrammer) error {
+		return writeConsist(ctx, p, c)
+	})
+}
+
+// ---------- helpers ----------
+
+func readConsist(ctx context.Context, p CVProgrammer) (Consist, error) {
+	v, err := p.ReadCV(ctx, CV_CONSIST_ADDRESS)
+	if err != nil {
+		return Consist{}, err
+	}
+	return DecodeConsist(v), nil
+}
+
+func writeConsist(ctx context.Context, p CVProgrammer, c Consist) error {
+	v, err := c.Encode()
+	if err != nil {
+		return err
+	}
+	return writeVerifyCV(ctx, p, CV_CONSIST_ADDRESS, v)
+}
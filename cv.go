@@ -0,0 +1,220 @@
+package z21
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrCvNack             = errors.New("z21: CV programming not acknowledged")
+	ErrCvNackShortCircuit = errors.New("z21: short circuit on programming track")
+	ErrCvVerify           = errors.New("z21: CV verification failed")
+)
+
+// LAN_X_CV_RESULT
+type CvResult struct {
+	CV    uint16
+	Value uint8
+}
+
+// ---------- Message interface ----------
+
+func (m *CvResult) Pack() ([]byte, error) {
+	cvAdr, err := encodeCV(m.CV)
+	if err != nil {
+		return nil, err
+	}
+	return packXBus(LAN_X_CV_RESULT, 0x14, byte(cvAdr>>8), byte(cvAdr), m.Value), nil
+}
+
+func (m *CvResult) Unpack(data []byte) error {
+	if len(data) < 5 {
+		return ErrBadPacket
+	}
+	m.CV = (uint16(data[2])<<8 | uint16(data[3])) + 1
+	m.Value = data[4]
+	return nil
+}
+
+func (m *CvResult) EncapType() uint16 {
+	return LAN_X
+}
+
+// ---------- Correlatable interface ----------
+
+func (m *CvResult) Key() (string, bool) {
+	return cvResultKey()
+}
+
+// LAN_X_CV_READ
+type CvRead struct {
+	CV uint16
+}
+
+// ---------- Message interface ----------
+
+func (m *CvRead) Pack() ([]byte, error) {
+	cvAdr, err := encodeCV(m.CV)
+	if err != nil {
+		return nil, err
+	}
+	return packXBus(LAN_X_23, LAN_X_CV_READ, byte(cvAdr>>8), byte(cvAdr)), nil
+}
+
+func (m *CvRead) Unpack(data []byte) error {
+	return nil
+}
+
+func (m *CvRead) EncapType() uint16 {
+	return LAN_X
+}
+
+// ---------- Correlatable interface ----------
+
+func (m *CvRead) Key() (string, bool) {
+	return cvResultKey()
+}
+
+// ReadCV reads a CV of a decoder on the programming track in direct
+// mode.
+func (nc *Conn) ReadCV(ctx context.Context, cv uint16) (uint8, error) {
+	var v uint8
+	err := nc.programming(ctx, func(p CVProgrammer) error {
+		var err error
+		v, err = p.ReadCV(ctx, cv)
+		return err
+	})
+	return v, err
+}
+
+// LAN_X_CV_WRITE
+type CvWrite struct {
+	CV    uint16
+	Value uint8
+}
+
+// ---------- Message interface ----------
+
+func (m *CvWrite) Pack() ([]byte, error) {
+	cvAdr, err := encodeCV(m.CV)
+	if err != nil {
+		return nil, err
+	}
+	return packXBus(LAN_X_24, LAN_X_CV_WRITE, byte(cvAdr>>8), byte(cvAdr), m.Value), nil
+}
+
+func (m *CvWrite) Unpack(data []byte) error {
+	return nil
+}
+
+func (m *CvWrite) EncapType() uint16 {
+	return LAN_X
+}
+
+// ---------- Correlatable interface ----------
+
+func (m *CvWrite) Key() (string, bool) {
+	return cvResultKey()
+}
+
+// WriteCV writes a CV of a decoder on the programming track in direct
+// mode. It returns ErrCvVerify if the Z21 reports a different value.
+func (nc *Conn) WriteCV(ctx context.Context, cv uint16, value uint8) error {
+	return nc.programming(ctx, func(p CVProgrammer) error {
+		return p.WriteCV(ctx, cv, value)
+	})
+}
+
+// LAN_X_CV_NACK and LAN_X_CV_NACK_SC
+type CvNack struct {
+	ShortCircuit bool
+}
+
+// ---------- Message interface ----------
+
+func (m *CvNack) Pack() ([]byte, error) {
+	if m.ShortCircuit {
+		return packXBus(LAN_X_61, LAN_X_CV_NACK_SC), nil
+	}
+	return packXBus(LAN_X_61, LAN_X_CV_NACK), nil
+}
+
+func (m *CvNack) Unpack(data []byte) error {
+	m.ShortCircuit = data[1] == LAN_X_CV_NACK_SC
+	return nil
+}
+
+func (m *CvNack) EncapType() uint16 {
+	return LAN_X
+}
+
+func (m *CvNack) Reject() error {
+	if m.ShortCircuit {
+		return ErrCvNackShortCircuit
+	}
+	return ErrCvNack
+}
+
+// ---------- Correlatable interface ----------
+
+func (m *CvNack) Key() (string, bool) {
+	return cvResultKey()
+}
+
+// ---------- helpers ----------
+
+func (nc *Conn) readCV(ctx context.Context, cv uint16) (uint8, error) {
+	resp, err := nc.SendRcv(ctx, &CvRead{CV: cv})
+	if err != nil {
+		return 0, err
+	}
+	return cvValue(resp, cv)
+}
+
+func (nc *Conn) writeCV(ctx context.Context, cv uint16, value uint8) error {
+	resp, err := nc.SendRcv(ctx, &CvWrite{CV: cv, Value: value})
+	if err != nil {
+		return err
+	}
+	v, err := cvValue(resp, cv)
+	if err != nil {
+		return err
+	}
+	if v != value {
+		return fmt.Errorf("%w: CV%d is %d, want %d", ErrCvVerify, cv, v, value)
+	}
+	return nil
+}
+
+// cvResultKey is shared by all programming requests answered with
+// LAN_X_CV_RESULT.
+func cvResultKey() (string, bool) {
+	d := []byte{byte(LAN_X), byte(LAN_X_CV_RESULT)}
+	f, err := fingerprint(d)
+	if err != nil {
+		return "", false
+	}
+	return f, true
+}
+
+// cvResult returns the LAN_X_CV_RESULT carried by a programming reply.
+func cvResult(resp Serializable) (*CvResult, error) {
+	res, ok := resp.(*CvResult)
+	if !ok {
+		return nil, fmt.Errorf("z21: unexpected programming reply %T", resp)
+	}
+	return res, nil
+}
+
+// cvValue extracts the value of the given CV from a programming reply.
+func cvValue(resp Serializable, cv uint16) (uint8, error) {
+	res, err := cvResult(resp)
+	if err != nil {
+		return 0, err
+	}
+	if res.CV != cv {
+		return 0, fmt.Errorf("z21: CV result for CV%d, want CV%d", res.CV, cv)
+	}
+	return res.Value, nil
+}
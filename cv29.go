@@ -0,0 +1,162 @@
+package z21
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	CV29_DIRECTION    uint8 = 0x01 // bit 0
+	CV29_SPEED_STEPS  uint8 = 0x02 // bit 1
+	CV29_ANALOG_MODE  uint8 = 0x04 // bit 2
+	CV29_RAILCOM      uint8 = 0x08 // bit 3
+	CV29_SPEED_TABLE  uint8 = 0x10 // bit 4
+	CV29_LONG_ADDRESS uint8 = 0x20 // bit 5
+	CV29_ACCESSORY    uint8 = 0x80 // bit 7
+)
+
+const MaxShortAddress uint16 = 127
+
+// CV29 is the decoder configuration stored in CV29. Accessory is set for
+// accessory decoders, which use the other bits differently. Consist
+// membership is not a CV29 bit; see Consist, which covers CV19.
+type CV29 struct {
+	Reversed     bool `json:"reversed"`
+	SpeedSteps28 bool `json:"speed_steps_28"`
+	AnalogMode   bool `json:"analog_mode"`
+	RailCom      bool `json:"railcom"`
+	SpeedTable   bool `json:"speed_table"`
+	LongAddress  bool `json:"long_address"`
+	Accessory    bool `json:"accessory"`
+}
+
+func DecodeCV29(v uint8) CV29 {
+	m := Mask8(v)
+	return CV29{
+		Reversed:     m.Has(CV29_DIRECTION),
+		SpeedSteps28: m.Has(CV29_SPEED_STEPS),
+		AnalogMode:   m.Has(CV29_ANALOG_MODE),
+		RailCom:      m.Has(CV29_RAILCOM),
+		SpeedTable:   m.Has(CV29_SPEED_TABLE),
+		LongAddress:  m.Has(CV29_LONG_ADDRESS),
+		Accessory:    m.Has(CV29_ACCESSORY),
+	}
+}
+
+func (c CV29) Encode() uint8 {
+	var v uint8
+	set := func(on bool, flag uint8) {
+		if on {
+			v |= flag
+		}
+	}
+	set(c.Reversed, CV29_DIRECTION)
+	set(c.SpeedSteps28, CV29_SPEED_STEPS)
+	set(c.AnalogMode, CV29_ANALOG_MODE)
+	set(c.RailCom, CV29_RAILCOM)
+	set(c.SpeedTable, CV29_SPEED_TABLE)
+	set(c.LongAddress, CV29_LONG_ADDRESS)
+	set(c.Accessory, CV29_ACCESSORY)
+	return v
+}
+
+// ReadCV29 reads the configuration of the decoder on the programming
+// track.
+func (nc *Conn) ReadCV29(ctx context.Context) (CV29, error) {
+	var c CV29
+	err := nc.programming(ctx, func(p CVProgrammer) error {
+		var err error
+		c, err = readCV29(ctx, p)
+		return err
+	})
+	return c, err
+}
+
+// WriteCV29 writes the configuration of the decoder on the programming
+// track. Bit 6, which the model does not cover, is preserved.
+func (nc *Conn) WriteCV29(ctx context.Context, c CV29) error {
+	return nc.programming(ctx, func(p CVProgrammer) error {
+		return writeCV29(ctx, p, c)
+	})
+}
+
+// ProgramAddress sets the address of the decoder on the programming
+// track. Addresses up to 127 are written to CV1, higher ones to CV17/18.
+//
+// The address CVs are written and verified before CV29 bit 5 is flipped.
+// When switching between a short and a long address, a failure therefore
+// leaves the decoder responding to its previous address. When the kind
+// of address stays the same, the new address takes effect as soon as
+// CV1, or CV17 and CV18, are written.
+func (nc *Conn) ProgramAddress(ctx context.Context, addr uint16) error {
+	return nc.programming(ctx, func(p CVProgrammer) error {
+		return programAddress(ctx, p, addr)
+	})
+}
+
+// ---------- helpers ----------
+
+func readCV29(ctx context.Context, p CVProgrammer) (CV29, error) {
+	v, err := p.ReadCV(ctx, CV_CONFIGURATION)
+	if err != nil {
+		return CV29{}, err
+	}
+	return DecodeCV29(v), nil
+}
+
+func writeCV29(ctx context.Context, p CVProgrammer, c CV29) error {
+	v, err := p.ReadCV(ctx, CV_CONFIGURATION)
+	if err != nil {
+		return err
+	}
+	return writeVerifyCV(ctx, p, CV_CONFIGURATION, v&0x40|c.Encode())
+}
+
+func programAddress(ctx context.Context, p CVProgrammer, addr uint16) error {
+	if addr < 1 || addr > MaxLocoAddress {
+		return ErrInvalidLocoAddress
+	}
+
+	cfg, err := p.ReadCV(ctx, CV_CONFIGURATION)
+	if err != nil {
+		return err
+	}
+
+	if addr <= MaxShortAddress {
+		if err := writeVerifyCV(ctx, p, CV_PRIMARY_ADDRESS, uint8(addr)); err != nil {
+			return err
+		}
+		cfg &^= CV29_LONG_ADDRESS
+	} else {
+		msb, lsb := encodeLongAddress(addr)
+		if err := writeVerifyCV(ctx, p, CV_EXTENDED_ADDRESS_MSB, msb); err != nil {
+			return err
+		}
+		if err := writeVerifyCV(ctx, p, CV_EXTENDED_ADDRESS_LSB, lsb); err != nil {
+			return err
+		}
+		cfg |= CV29_LONG_ADDRESS
+	}
+
+	return writeVerifyCV(ctx, p, CV_CONFIGURATION, cfg)
+}
+
+// writeVerifyCV writes a CV and reads it back.
+func writeVerifyCV(ctx context.Context, p CVProgrammer, cv uint16, value uint8) error {
+	if err := p.WriteCV(ctx, cv, value); err != nil {
+		return err
+	}
+	v, err := p.ReadCV(ctx, cv)
+	if err != nil {
+		return err
+	}
+	if v != value {
+		return fmt.Errorf("%w: CV%d is %d, want %d", ErrCvVerify, cv, v, value)
+	}
+	return nil
+}
+
+// encodeLongAddress returns the CV17 and CV18 values of a long address.
+func encodeLongAddress(addr uint16) (uint8, uint8) {
+	return 0xC0 | uint8(addr>>8), uint8(addr)
+}
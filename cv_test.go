@@ -0,0 +1,45 @@
+package z21
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCvReplyRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		m      Serializable
+		reject error
+	}{
+		{name: "result CV 1", m: &CvResult{CV: 1, Value: 3}},
+		{name: "result CV 300", m: &CvResult{CV: 300, Value: 0xFF}},
+		{name: "result CV 1024", m: &CvResult{CV: 1024, Value: 0}},
+		{name: "nack", m: &CvNack{}, reject: ErrCvNack},
+		{name: "nack short circuit", m: &CvNack{ShortCircuit: true}, reject: ErrCvNackShortCircuit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := roundTrip(t, tt.m)
+			if !reflect.DeepEqual(got, tt.m) {
+				t.Errorf("round trip = %+v, want %+v", got, tt.m)
+			}
+			if r, ok := got.(Rejection); ok || tt.reject != nil {
+				if !ok {
+					t.Fatalf("%T is not a Rejection", got)
+				}
+				if err := r.Reject(); !errors.Is(err, tt.reject) {
+					t.Errorf("Reject() = %v, want %v", err, tt.reject)
+				}
+			}
+		})
+	}
+}
+
+func TestCvResultUnpackShort(t *testing.T) {
+	var m CvResult
+	if err := m.Unpack([]byte{LAN_X_CV_RESULT, 0x14, 0x00, 0x00}); !errors.Is(err, ErrBadPacket) {
+		t.Errorf("Unpack() error = %v, want %v", err, ErrBadPacket)
+	}
+}
@@ -0,0 +1,199 @@
+package z21
+
+import (
+	"sync"
+	"time"
+)
+
+// DebounceConfig configures the filtering of detector messages.
+//
+// A sensor change is only reported once the sensor has held its new
+// state for MinOccupied or MinFree. In addition, a sensor listed in
+// Neighbours is not reported free within NeighbourWindow of one of its
+// neighbours becoming occupied; the free report is held back until the
+// window has passed and dropped if the sensor is occupied again by then.
+type DebounceConfig struct {
+	MinOccupied     time.Duration
+	MinFree         time.Duration
+	Neighbours      [][2]Sensor
+	NeighbourWindow time.Duration
+}
+
+// detectorFilter debounces R-BUS, CAN and LocoNet occupancy messages.
+// The first report of a sensor passes unfiltered. Debounced changes are
+// re-emitted as the same message types: CAN and LocoNet messages as last
+// received, R-BUS messages rebuilt from the debounced input states of
+// their group.
+type detectorFilter struct {
+	cfg        DebounceConfig
+	emit       func(Serializable)
+	neighbours map[Sensor][]Sensor
+
+	mu      sync.Mutex
+	sensors map[Sensor]*sensorFilter
+}
+
+type sensorFilter struct {
+	raw        bool
+	reported   bool
+	known      bool
+	changedAt  time.Time
+	occupiedAt time.Time
+	timer      *time.Timer
+	msg        Serializable
+}
+
+func newDetectorFilter(cfg DebounceConfig, emit func(Serializable)) *detectorFilter {
+	f := &detectorFilter{
+		cfg:        cfg,
+		emit:       emit,
+		neighbours: make(map[Sensor][]Sensor),
+		sensors:    make(map[Sensor]*sensorFilter),
+	}
+	for _, n := range cfg.Neighbours {
+		f.neighbours[n[0]] = append(f.neighbours[n[0]], n[1])
+		f.neighbours[n[1]] = append(f.neighbours[n[1]], n[0])
+	}
+	return f
+}
+
+// handle filters a detector message and reports whether it was one.
+func (f *detectorFilter) handle(m Serializable) bool {
+	now := time.Now()
+
+	f.mu.Lock()
+	var out Serializable
+	switch m := m.(type) {
+	case *CanOccupancy:
+		s := CanSensor(m.NetworkID, m.Address, m.Port)
+		f.sensor(s).msg = m
+		if f.update(s, m.Occupied(), now) {
+			out = m
+		}
+	case *LocoNetOccupancy:
+		s := LocoNetSensor(m.Address)
+		f.sensor(s).msg = m
+		if f.update(s, m.Occupied, now) {
+			out = m
+		}
+	case *RBusFeedback:
+		changed := false
+		base := uint16(m.Group) * RBusModulesPerGroup
+		for i, mod := range m.Modules {
+			for input := uint8(1); input <= RBusInputsPerModule; input++ {
+				s := RBusSensor(base+uint16(i)+1, input)
+				if f.update(s, mod.Has(1<<(input-1)), now) {
+					changed = true
+				}
+			}
+		}
+		if changed {
+			out = f.rbusMessage(m.Group)
+		}
+	default:
+		f.mu.Unlock()
+		return false
+	}
+	f.mu.Unlock()
+
+	if out != nil {
+		f.emit(out)
+	}
+	return true
+}
+
+// ---------- helpers ----------
+
+func (f *detectorFilter) sensor(s Sensor) *sensorFilter {
+	st, ok := f.sensors[s]
+	if !ok {
+		st = &sensorFilter{}
+		f.sensors[s] = st
+	}
+	return st
+}
+
+// update records a raw sensor state and reports whether the change can
+// be reported right away.
+func (f *detectorFilter) update(s Sensor, occupied bool, now time.Time) bool {
+	st := f.sensor(s)
+	if occupied != st.raw || !st.known {
+		st.changedAt = now
+		if occupied {
+			st.occupiedAt = now
+		}
+	}
+	st.raw = occupied
+
+	if !st.known {
+		st.known = true
+		st.reported = occupied
+		return true
+	}
+	return f.settle(s, st, now)
+}
+
+// settle reports the raw state if it has been stable long enough and is
+// plausible, or schedules another attempt.
+func (f *detectorFilter) settle(s Sensor, st *sensorFilter, now time.Time) bool {
+	if st.timer != nil {
+		st.timer.Stop()
+		st.timer = nil
+	}
+	if st.raw == st.reported {
+		return false
+	}
+
+	due := st.changedAt.Add(f.cfg.MinFree)
+	if st.raw {
+		due = st.changedAt.Add(f.cfg.MinOccupied)
+	} else {
+		for _, n := range f.neighbours[s] {
+			if nst, ok := f.sensors[n]; ok && !nst.occupiedAt.IsZero() {
+				if until := nst.occupiedAt.Add(f.cfg.NeighbourWindow); until.After(due) {
+					due = until
+				}
+			}
+		}
+	}
+
+	if !now.Before(due) {
+		st.reported = st.raw
+		return true
+	}
+	st.timer = time.AfterFunc(due.Sub(now), func() {
+		f.fire(s)
+	})
+	return false
+}
+
+func (f *detectorFilter) fire(s Sensor) {
+	f.mu.Lock()
+	st := f.sensors[s]
+	var out Serializable
+	if f.settle(s, st, time.Now()) {
+		out = st.msg
+		if s.Source == SensorRBus {
+			out = f.rbusMessage(uint8((s.Module - 1) / RBusModulesPerGroup))
+		}
+	}
+	f.mu.Unlock()
+
+	if out != nil {
+		f.emit(out)
+	}
+}
+
+// rbusMessage builds an R-BUS group message from the reported states.
+func (f *detectorFilter) rbusMessage(group uint8) *RBusFeedback {
+	fb := &RBusFeedback{Group: group}
+	base := uint16(group) * RBusModulesPerGroup
+	for i := range fb.Modules {
+		for input := uint8(1); input <= RBusInputsPerModule; input++ {
+			if st, ok := f.sensors[RBusSensor(base+uint16(i)+1, input)]; ok && st.reported {
+				fb.Modules[i] |= Mask8(1 << (input - 1))
+			}
+		}
+	}
+	return fb
+}
@@ -0,0 +1,160 @@
+package z21
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type report struct {
+	addr     uint16
+	occupied bool
+}
+
+type step struct {
+	after    time.Duration
+	addr     uint16
+	occupied bool
+}
+
+func TestDetectorFilter(t *testing.T) {
+	const (
+		hold   = 40 * time.Millisecond
+		window = 100 * time.Millisecond
+		short  = 5 * time.Millisecond
+		settle = 250 * time.Millisecond
+	)
+	debounce := DebounceConfig{MinOccupied: hold, MinFree: hold}
+	neighbours := DebounceConfig{
+		Neighbours:      [][2]Sensor{{LocoNetSensor(1), LocoNetSensor(2)}},
+		NeighbourWindow: window,
+	}
+
+	tests := []struct {
+		name  string
+		cfg   DebounceConfig
+		steps []step
+		// immediate is reported right after the steps, want once all
+		// timers have fired.
+		immediate []report
+		want      []report
+	}{
+		{
+			name:      "first report passes",
+			cfg:       debounce,
+			steps:     []step{{0, 1, true}},
+			immediate: []report{{1, true}},
+			want:      []report{{1, true}},
+		},
+		{
+			name:      "occupied glitch dropped",
+			cfg:       debounce,
+			steps:     []step{{0, 1, false}, {0, 1, true}, {short, 1, false}},
+			immediate: []report{{1, false}},
+			want:      []report{{1, false}},
+		},
+		{
+			name:      "occupied after MinOccupied",
+			cfg:       debounce,
+			steps:     []step{{0, 1, false}, {0, 1, true}},
+			immediate: []report{{1, false}},
+			want:      []report{{1, false}, {1, true}},
+		},
+		{
+			name:      "free glitch dropped",
+			cfg:       debounce,
+			steps:     []step{{0, 1, true}, {0, 1, false}, {short, 1, true}},
+			immediate: []report{{1, true}},
+			want:      []report{{1, true}},
+		},
+		{
+			name:      "free after MinFree",
+			cfg:       debounce,
+			steps:     []step{{0, 1, true}, {0, 1, false}},
+			immediate: []report{{1, true}},
+			want:      []report{{1, true}, {1, false}},
+		},
+		{
+			name:      "no debounce",
+			cfg:       DebounceConfig{},
+			steps:     []step{{0, 1, false}, {0, 1, true}, {0, 1, false}},
+			immediate: []report{{1, false}, {1, true}, {1, false}},
+			want:      []report{{1, false}, {1, true}, {1, false}},
+		},
+		{
+			name:      "free held within neighbour window",
+			cfg:       neighbours,
+			steps:     []step{{0, 1, true}, {0, 2, false}, {0, 2, true}, {short, 1, false}},
+			immediate: []report{{1, true}, {2, false}, {2, true}},
+			want:      []report{{1, true}, {2, false}, {2, true}, {1, false}},
+		},
+		{
+			name:      "free dropped when occupied again within window",
+			cfg:       neighbours,
+			steps:     []step{{0, 1, true}, {0, 2, false}, {0, 2, true}, {short, 1, false}, {short, 1, true}},
+			immediate: []report{{1, true}, {2, false}, {2, true}},
+			want:      []report{{1, true}, {2, false}, {2, true}},
+		},
+		{
+			name:      "free after neighbour window",
+			cfg:       neighbours,
+			steps:     []step{{0, 1, true}, {0, 2, false}, {0, 2, true}, {window + short, 1, false}},
+			immediate: []report{{1, true}, {2, false}, {2, true}, {1, false}},
+			want:      []report{{1, true}, {2, false}, {2, true}, {1, false}},
+		},
+		{
+			name:      "other sensors not held",
+			cfg:       neighbours,
+			steps:     []step{{0, 3, true}, {0, 2, false}, {0, 2, true}, {short, 3, false}},
+			immediate: []report{{3, true}, {2, false}, {2, true}, {3, false}},
+			want:      []report{{3, true}, {2, false}, {2, true}, {3, false}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var mu sync.Mutex
+			var got []report
+			f := newDetectorFilter(tt.cfg, func(m Serializable) {
+				o := m.(*LocoNetOccupancy)
+				mu.Lock()
+				got = append(got, report{o.Address, o.Occupied})
+				mu.Unlock()
+			})
+			reports := func() []report {
+				mu.Lock()
+				defer mu.Unlock()
+				return append([]report(nil), got...)
+			}
+
+			for _, s := range tt.steps {
+				time.Sleep(s.after)
+				m := &LocoNetOccupancy{Occupied: s.occupied}
+				m.Address = s.addr
+				if !f.handle(m) {
+					t.Fatalf("handle(%+v) = false", s)
+				}
+			}
+			if r := reports(); !reflect.DeepEqual(r, tt.immediate) {
+				t.Errorf("immediate reports = %v, want %v", r, tt.immediate)
+			}
+
+			time.Sleep(settle)
+			if r := reports(); !reflect.DeepEqual(r, tt.want) {
+				t.Errorf("reports = %v, want %v", r, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectorFilterIgnoresOtherMessages(t *testing.T) {
+	f := newDetectorFilter(DebounceConfig{}, func(Serializable) {
+		t.Error("unexpected emit")
+	})
+	if f.handle(&TrackPower{On: true}) {
+		t.Error("handle(TrackPower) = true")
+	}
+}
@@ -0,0 +1,81 @@
+package z21
+
+import "context"
+
+const (
+	CV_PRIMARY_ADDRESS      uint16 = 1
+	CV_VERSION              uint16 = 7
+	CV_MANUFACTURER         uint16 = 8
+	CV_EXTENDED_ADDRESS_MSB uint16 = 17
+	CV_EXTENDED_ADDRESS_LSB uint16 = 18
+	CV_CONSIST_ADDRESS      uint16 = 19
+	CV_CONFIGURATION        uint16 = 29
+)
+
+// DecoderInfo describes a decoder identified on the programming track.
+type DecoderInfo struct {
+	Manufacturer Manufacturer `json:"manufacturer"`
+	Version      uint8        `json:"version"`
+	Address      uint16       `json:"address"`
+	Config       CV29         `json:"config"`
+}
+
+// IdentifyDecoder reads the identification and addressing CVs of the
+// decoder on the programming track.
+func (nc *Conn) IdentifyDecoder(ctx context.Context) (*DecoderInfo, error) {
+	var info *DecoderInfo
+	err := nc.programming(ctx, func(p CVProgrammer) error {
+		var err error
+		info, err = identifyDecoder(ctx, p)
+		return err
+	})
+	return info, err
+}
+
+// ---------- helpers ----------
+
+func identifyDecoder(ctx context.Context, p CVProgrammer) (*DecoderInfo, error) {
+	cvs := []uint16{
+		CV_PRIMARY_ADDRESS,
+		CV_VERSION,
+		CV_MANUFACTURER,
+		CV_EXTENDED_ADDRESS_MSB,
+		CV_EXTENDED_ADDRESS_LSB,
+		CV_CONFIGURATION,
+	}
+	values := make(map[uint16]uint8, len(cvs))
+	hasLong := true
+	for _, cv := range cvs {
+		v, err := p.ReadCV(ctx, cv)
+		if err != nil {
+			// Decoders limited to short addresses may not answer CV17/18.
+			if isLongAddressCV(cv) && isUnanswered(err) {
+				hasLong = false
+				continue
+			}
+			return nil, err
+		}
+		values[cv] = v
+	}
+
+	info := &DecoderInfo{
+		Manufacturer: LookupManufacturer(values[CV_MANUFACTURER]),
+		Version:      values[CV_VERSION],
+		Config:       DecodeCV29(values[CV_CONFIGURATION]),
+	}
+	if info.Config.LongAddress && hasLong {
+		info.Address = decodeLongAddress(values[CV_EXTENDED_ADDRESS_MSB], values[CV_EXTENDED_ADDRESS_LSB])
+	} else {
+		info.Address = uint16(values[CV_PRIMARY_ADDRESS] & 0x7F)
+	}
+	return info, nil
+}
+
+func isLongAddressCV(cv uint16) bool {
+	return cv == CV_EXTENDED_ADDRESS_MSB || cv == CV_EXTENDED_ADDRESS_LSB
+}
+
+// decodeLongAddress decodes a long address stored in CV17 and CV18.
+func decodeLongAddress(msb, lsb uint8) uint16 {
+	return uint16(msb&0x3F)<<8 | uint16(lsb)
+}
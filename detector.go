@@ -0,0 +1,155 @@
+package z21
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+const DefaultDetectorWindow = 500 * time.Millisecond
+
+// DetectorSet aggregates CAN detector replies into Detector values, one
+// per module and network ID.
+type DetectorSet struct {
+	mu      sync.Mutex
+	modules map[detectorKey]*detectorState
+}
+
+type detectorKey struct {
+	nid  uint16
+	addr uint16
+}
+
+type detectorState struct {
+	ports map[uint8]*portState
+}
+
+type portState struct {
+	status  uint16
+	railcom map[uint8][]RailComLoco // by message type
+}
+
+func NewDetectorSet() *DetectorSet {
+	return &DetectorSet{modules: make(map[detectorKey]*detectorState)}
+}
+
+// Update merges a CanOccupancy or CanRailCom message into the set and
+// reports whether it was one.
+func (s *DetectorSet) Update(m Serializable) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch m := m.(type) {
+	case *CanOccupancy:
+		s.port(&m.CanDetector).status = m.Status
+	case *CanRailCom:
+		s.port(&m.CanDetector).railcom[m.Type] = m.Locos
+	default:
+		return false
+	}
+	return true
+}
+
+// Detectors returns the aggregated detectors ordered by network ID and
+// module address, with ports ordered by index.
+func (s *DetectorSet) Detectors() []Detector {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var dets []Detector
+	for k, st := range s.modules {
+		d := Detector{NetworkID: k.nid, Address: k.addr}
+		for idx, ps := range st.ports {
+			d.Ports = append(d.Ports, DetectorPort{
+				Index:  idx,
+				Status: ps.status,
+				Locos:  ps.locos(),
+			})
+		}
+		sort.Slice(d.Ports, func(i, j int) bool {
+			return d.Ports[i].Index < d.Ports[j].Index
+		})
+		dets = append(dets, d)
+	}
+	sort.Slice(dets, func(i, j int) bool {
+		if dets[i].NetworkID != dets[j].NetworkID {
+			return dets[i].NetworkID < dets[j].NetworkID
+		}
+		return dets[i].Address < dets[j].Address
+	})
+	return dets
+}
+
+// QueryCanDetectors requests the state of the CAN detector with the given
+// network ID, or of all detectors for CAN_BROADCAST_NID, and aggregates
+// the replies arriving within window.
+func (nc *Conn) QueryCanDetectors(ctx context.Context, nid uint16, window time.Duration) ([]Detector, error) {
+	set := NewDetectorSet()
+	cancel := nc.watch(func(m Serializable) {
+		if nid != CAN_BROADCAST_NID && canNetworkID(m) != nid {
+			return
+		}
+		set.Update(m)
+	})
+	defer cancel()
+
+	if _, err := nc.SendRcv(ctx, &CanDetector{NetworkID: nid}); err != nil {
+		return nil, err
+	}
+
+	t := time.NewTimer(window)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-t.C:
+	}
+
+	return set.Detectors(), nil
+}
+
+// ---------- helpers ----------
+
+func (s *DetectorSet) port(m *CanDetector) *portState {
+	k := detectorKey{nid: m.NetworkID, addr: m.Address}
+	st, ok := s.modules[k]
+	if !ok {
+		st = &detectorState{ports: make(map[uint8]*portState)}
+		s.modules[k] = st
+	}
+	ps, ok := st.ports[m.Port]
+	if !ok {
+		ps = &portState{railcom: make(map[uint8][]RailComLoco)}
+		st.ports[m.Port] = ps
+	}
+	return ps
+}
+
+// locos flattens the RailCom addresses in message type order.
+func (ps *portState) locos() []RailComLoco {
+	types := make([]uint8, 0, len(ps.railcom))
+	for t := range ps.railcom {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+
+	var locos []RailComLoco
+	for _, t := range types {
+		locos = append(locos, ps.railcom[t]...)
+	}
+	return locos
+}
+
+func canNetworkID(m Serializable) uint16 {
+	switch m := m.(type) {
+	case *CanOccupancy:
+		return m.NetworkID
+	case *CanRailCom:
+		return m.NetworkID
+	case *CanDetector:
+		return m.NetworkID
+	default:
+		return 0
+	}
+}
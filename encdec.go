@@ -24,3 +24,22 @@ func fingerprint(data []byte) (string, error) {
 	}
 	return hex.EncodeToString(h.Sum(nil)), nil
 }
+
+// packXBus appends the X-Bus XOR checksum to the given header and data
+// bytes.
+func packXBus(data ...byte) []byte {
+	var xor byte
+	for _, b := range data {
+		xor ^= b
+	}
+	return append(data, xor)
+}
+
+// encodeCV converts a 1-based CV number into the 0-based CV address used
+// on the wire.
+func encodeCV(cv uint16) (uint16, error) {
+	if cv < 1 || cv > MaxCV {
+		return 0, ErrInvalidCV
+	}
+	return cv - 1, nil
+}
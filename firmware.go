@@ -0,0 +1,85 @@
+package z21
+
+import (
+	"context"
+	"fmt"
+)
+
+// LAN_X_GET_FIRMWARE_VERSION
+type FirmwareVersion struct {
+	Major int
+	Minor int
+}
+
+// ---------- Message interface ----------
+
+func (m *FirmwareVersion) String() string {
+	return fmt.Sprintf("%d.%02d", m.Major, m.Minor)
+}
+
+func (m *FirmwareVersion) Pack() ([]byte, error) {
+	return packXBus(LAN_X_F1, LAN_X_GET_FIRMWARE_VERSION), nil
+}
+
+func (m *FirmwareVersion) Unpack(data []byte) error {
+	if len(data) < 4 {
+		return ErrBadPacket
+	}
+	major, err := decodeBCDByte(data[2])
+	if err != nil {
+		return err
+	}
+	minor, err := decodeBCDByte(data[3])
+	if err != nil {
+		return err
+	}
+	m.Major, m.Minor = major, minor
+	return nil
+}
+
+func (m *FirmwareVersion) EncapType() uint16 {
+	return LAN_X
+}
+
+// AtLeast reports whether the firmware is the given version or newer.
+func (m *FirmwareVersion) AtLeast(major, minor int) bool {
+	if m.Major != major {
+		return m.Major > major
+	}
+	return m.Minor >= minor
+}
+
+// ---------- Correlatable interface ----------
+
+func (m *FirmwareVersion) Key() (string, bool) {
+	d := []byte{byte(LAN_X), LAN_X_F3, LAN_X_GET_FIRMWARE_VERSION}
+	f, err := fingerprint(d)
+	if err != nil {
+		return "", false
+	}
+	return f, true
+}
+
+// Firmware queries the firmware version of the Z21.
+func (nc *Conn) Firmware(ctx context.Context) (*FirmwareVersion, error) {
+	resp, err := nc.SendRcv(ctx, &FirmwareVersion{})
+	if err != nil {
+		return nil, err
+	}
+	fw, ok := resp.(*FirmwareVersion)
+	if !ok {
+		return nil, fmt.Errorf("z21: unexpected firmware reply %T", resp)
+	}
+	return fw, nil
+}
+
+// ---------- helpers ----------
+
+// decodeBCDByte decodes a two digit BCD byte, e.g. 0x42 into 42.
+func decodeBCDByte(b byte) (int, error) {
+	high, low, err := decodeBCD(b)
+	if err != nil {
+		return 0, err
+	}
+	return high*10 + low, nil
+}
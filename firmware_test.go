@@ -0,0 +1,90 @@
+package z21
+
+import (
+	"context"
+	"testing"
+)
+
+// rawX is a LAN_X reply given as its X-Bus bytes.
+type rawX []byte
+
+func (m rawX) Pack() ([]byte, error) { return xbus(m...), nil }
+func (m rawX) Unpack([]byte) error   { return nil }
+func (m rawX) EncapType() uint16     { return LAN_X }
+func (m rawX) Key() (string, bool)   { return "", false }
+
+func TestFirmwareVersionUnpack(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   []byte
+		want   string
+		major  int
+		minor  int
+		newer  [2]int // AtLeast true
+		older  [2]int // AtLeast false
+		reject bool
+	}{
+		{name: "1.43", data: xbus(0xF3, 0x0A, 0x01, 0x43), want: "1.43", major: 1, minor: 43, newer: [2]int{1, 40}, older: [2]int{1, 44}},
+		{name: "1.05", data: xbus(0xF3, 0x0A, 0x01, 0x05), want: "1.05", major: 1, minor: 5, newer: [2]int{0, 99}, older: [2]int{1, 10}},
+		{name: "10.99", data: xbus(0xF3, 0x0A, 0x10, 0x99), want: "10.99", major: 10, minor: 99, newer: [2]int{10, 99}, older: [2]int{11, 0}},
+		{name: "invalid BCD", data: xbus(0xF3, 0x0A, 0x01, 0x4A), reject: true},
+		{name: "truncated", data: []byte{0xF3, 0x0A, 0x01}, reject: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fw FirmwareVersion
+			err := fw.Unpack(tt.data)
+			if tt.reject {
+				if err == nil {
+					t.Fatalf("Unpack(% X) = %v, want error", tt.data, &fw)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unpack(% X) error = %v", tt.data, err)
+			}
+			if fw.Major != tt.major || fw.Minor != tt.minor {
+				t.Errorf("Unpack(% X) = %d.%d, want %d.%d", tt.data, fw.Major, fw.Minor, tt.major, tt.minor)
+			}
+			if s := fw.String(); s != tt.want {
+				t.Errorf("String() = %q, want %q", s, tt.want)
+			}
+			if !fw.AtLeast(tt.newer[0], tt.newer[1]) {
+				t.Errorf("AtLeast(%d, %d) = false", tt.newer[0], tt.newer[1])
+			}
+			if fw.AtLeast(tt.older[0], tt.older[1]) {
+				t.Errorf("AtLeast(%d, %d) = true", tt.older[0], tt.older[1])
+			}
+		})
+	}
+}
+
+func TestFirmware(t *testing.T) {
+	nc := connectFake(t, func(req Frame) []Serializable {
+		if req.Header == LAN_X && len(req.Payload) > 1 &&
+			req.Payload[0] == LAN_X_F1 && req.Payload[1] == LAN_X_GET_FIRMWARE_VERSION {
+			return []Serializable{rawX{LAN_X_F3, LAN_X_GET_FIRMWARE_VERSION, 0x01, 0x43}}
+		}
+		return nil
+	})
+
+	fw, err := nc.Firmware(context.Background())
+	if err != nil {
+		t.Fatalf("Firmware() error = %v", err)
+	}
+	if fw.Major != 1 || fw.Minor != 43 {
+		t.Errorf("Firmware() = %v, want 1.43", fw)
+	}
+}
+
+func TestDecodeBCDByte(t *testing.T) {
+	for _, b := range []byte{0x0A, 0xA0, 0xFF} {
+		if _, err := decodeBCDByte(b); err == nil {
+			t.Errorf("decodeBCDByte(%#02x) succeeded", b)
+		}
+	}
+	if v, err := decodeBCDByte(0x42); v != 42 || err != nil {
+		t.Errorf("decodeBCDByte(0x42) = %d, %v, want 42", v, err)
+	}
+}
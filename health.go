@@ -0,0 +1,298 @@
+package z21
+
+import (
+	"sync"
+	"time"
+)
+
+type AlarmKind uint8
+
+const (
+	AlarmOvercurrent AlarmKind = iota
+	AlarmTemperature
+	AlarmUndervoltage
+	AlarmRepeatedShorts
+)
+
+func (k AlarmKind) String() string {
+	switch k {
+	case AlarmOvercurrent:
+		return "overcurrent"
+	case AlarmTemperature:
+		return "temperature"
+	case AlarmUndervoltage:
+		return "undervoltage"
+	case AlarmRepeatedShorts:
+		return "repeated shorts"
+	default:
+		return "unknown"
+	}
+}
+
+// HealthConfig configures a HealthMonitor. Zero fields take the default
+// given in their comment.
+type HealthConfig struct {
+	// MaxCurrent is the main track current in mA that raises
+	// AlarmOvercurrent, either when reached or when the trend over
+	// TrendWindow predicts it within TrendHorizon. 3000 by default.
+	MaxCurrent        int
+	CurrentHysteresis int           // mA, 200
+	TrendWindow       time.Duration // 30s
+	TrendHorizon      time.Duration // 60s
+
+	// MaxTemperature in °C raises AlarmTemperature. 65 by default.
+	MaxTemperature        int
+	TemperatureHysteresis int // °C, 5
+
+	// MinSupplyVoltage in mV raises AlarmUndervoltage. 15000 by default.
+	MinSupplyVoltage  int
+	VoltageHysteresis int // mV, 500
+
+	// MaxShorts short circuits within ShortWindow raise
+	// AlarmRepeatedShorts. 3 within 5 minutes by default.
+	MaxShorts   int
+	ShortWindow time.Duration
+
+	// History is the number of readings kept. 600 by default.
+	History int
+}
+
+// Alarm reports an alarm being raised or cleared. Value is the reading
+// that caused the change, in the unit of the alarm's threshold, or the
+// number of recent shorts.
+type Alarm struct {
+	Kind   AlarmKind
+	Active bool
+	Value  int
+	Time   time.Time
+}
+
+// HealthReading is one SysData sample in mA, °C and mV.
+type HealthReading struct {
+	Time                time.Time
+	MainCurrent         int
+	ProgCurrent         int
+	FilteredMainCurrent int
+	Temperature         int
+	SupplyVoltage       int
+	VccVoltage          int
+}
+
+// HealthMonitor raises alarms from SysData, Status and short circuit
+// messages. Each alarm is raised when its threshold is crossed and
+// cleared only once the reading is back past the threshold by the
+// hysteresis.
+type HealthMonitor struct {
+	cfg HealthConfig
+
+	mu       sync.Mutex
+	history  []HealthReading
+	next     int
+	active   map[AlarmKind]bool
+	short    bool
+	shortsAt []time.Time
+	events   chan Alarm
+}
+
+func NewHealthMonitor(cfg HealthConfig) *HealthMonitor {
+	defaultInt(&cfg.MaxCurrent, 3000)
+	defaultInt(&cfg.CurrentHysteresis, 200)
+	defaultDuration(&cfg.TrendWindow, 30*time.Second)
+	defaultDuration(&cfg.TrendHorizon, 60*time.Second)
+	defaultInt(&cfg.MaxTemperature, 65)
+	defaultInt(&cfg.TemperatureHysteresis, 5)
+	defaultInt(&cfg.MinSupplyVoltage, 15000)
+	defaultInt(&cfg.VoltageHysteresis, 500)
+	defaultInt(&cfg.MaxShorts, 3)
+	defaultDuration(&cfg.ShortWindow, 5*time.Minute)
+	defaultInt(&cfg.History, 600)
+
+	return &HealthMonitor{
+		cfg:    cfg,
+		active: make(map[AlarmKind]bool),
+		events: make(chan Alarm, defaultEventBufSize),
+	}
+}
+
+// Events returns alarm changes. Events are dropped if the channel is not
+// drained.
+func (h *HealthMonitor) Events() <-chan Alarm {
+	return h.events
+}
+
+// Update applies a *SysData, *Status or *TrackShortCircuit message.
+// Other messages are ignored.
+func (h *HealthMonitor) Update(m Serializable) {
+	now := time.Now()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	switch m := m.(type) {
+	case *SysData:
+		r := HealthReading{
+			Time:                now,
+			MainCurrent:         m.MainCurrentMA(),
+			ProgCurrent:         m.ProgCurrentMA(),
+			FilteredMainCurrent: m.FilteredMainCurrentMA(),
+			Temperature:         m.TemperatureC(),
+			SupplyVoltage:       m.SupplyVoltageMV(),
+			VccVoltage:          m.VccVoltageMV(),
+		}
+		h.record(r)
+		h.checkCurrent(r, now)
+		h.checkTemperature(r, now)
+		h.checkVoltage(r, now)
+		h.shortState(m.CentralState.Has(SHORT_CIRCUIT) ||
+			m.ShortCircuitExternal() || m.ShortCircuitInternal(), now)
+	case *Status:
+		h.shortState(m.Mask.Has(SHORT_CIRCUIT), now)
+	case *TrackShortCircuit:
+		h.shortState(true, now)
+	}
+}
+
+// Active returns the alarms currently raised.
+func (h *HealthMonitor) Active() []AlarmKind {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var kinds []AlarmKind
+	for k := AlarmOvercurrent; k <= AlarmRepeatedShorts; k++ {
+		if h.active[k] {
+			kinds = append(kinds, k)
+		}
+	}
+	return kinds
+}
+
+// History returns the readings kept, oldest first.
+func (h *HealthMonitor) History() []HealthReading {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.readings()
+}
+
+// MonitorHealth feeds all messages delivered on Events() into h until
+// the returned cancel function is called. Without SYSTEM_UPDATES
+// broadcasts, run PollSystemState to get readings.
+func (nc *Conn) MonitorHealth(h *HealthMonitor) (cancel func()) {
+	return nc.watchEvents(h.Update)
+}
+
+// ---------- helpers ----------
+
+func (h *HealthMonitor) record(r HealthReading) {
+	if len(h.history) < h.cfg.History {
+		h.history = append(h.history, r)
+		return
+	}
+	h.history[h.next] = r
+	h.next = (h.next + 1) % h.cfg.History
+}
+
+func (h *HealthMonitor) readings() []HealthReading {
+	out := make([]HealthReading, 0, len(h.history))
+	out = append(out, h.history[h.next:]...)
+	return append(out, h.history[:h.next]...)
+}
+
+// checkCurrent raises AlarmOvercurrent when the filtered current reaches
+// the limit or its linear trend over the window would within the
+// horizon.
+func (h *HealthMonitor) checkCurrent(r HealthReading, now time.Time) {
+	cur := r.FilteredMainCurrent
+	projected := cur
+	if slope, ok := h.currentSlope(now); ok && slope > 0 {
+		projected += int(slope * h.cfg.TrendHorizon.Seconds())
+	}
+
+	if projected >= h.cfg.MaxCurrent {
+		h.set(AlarmOvercurrent, true, cur, now)
+	} else if projected < h.cfg.MaxCurrent-h.cfg.CurrentHysteresis {
+		h.set(AlarmOvercurrent, false, cur, now)
+	}
+}
+
+// currentSlope returns the least squares slope of the filtered main
+// current over the trend window in mA/s.
+func (h *HealthMonitor) currentSlope(now time.Time) (float64, bool) {
+	var n, sx, sy, sxx, sxy float64
+	for _, r := range h.readings() {
+		if now.Sub(r.Time) > h.cfg.TrendWindow {
+			continue
+		}
+		x := r.Time.Sub(now).Seconds()
+		y := float64(r.FilteredMainCurrent)
+		n++
+		sx += x
+		sy += y
+		sxx += x * x
+		sxy += x * y
+	}
+	d := n*sxx - sx*sx
+	if n < 3 || d == 0 {
+		return 0, false
+	}
+	return (n*sxy - sx*sy) / d, true
+}
+
+func (h *HealthMonitor) checkTemperature(r HealthReading, now time.Time) {
+	if r.Temperature >= h.cfg.MaxTemperature {
+		h.set(AlarmTemperature, true, r.Temperature, now)
+	} else if r.Temperature <= h.cfg.MaxTemperature-h.cfg.TemperatureHysteresis {
+		h.set(AlarmTemperature, false, r.Temperature, now)
+	}
+}
+
+func (h *HealthMonitor) checkVoltage(r HealthReading, now time.Time) {
+	if r.SupplyVoltage < h.cfg.MinSupplyVoltage {
+		h.set(AlarmUndervoltage, true, r.SupplyVoltage, now)
+	} else if r.SupplyVoltage >= h.cfg.MinSupplyVoltage+h.cfg.VoltageHysteresis {
+		h.set(AlarmUndervoltage, false, r.SupplyVoltage, now)
+	}
+}
+
+// shortState counts short circuits on their rising edge and raises
+// AlarmRepeatedShorts while too many fall within the window.
+func (h *HealthMonitor) shortState(short bool, now time.Time) {
+	if short && !h.short {
+		h.shortsAt = append(h.shortsAt, now)
+	}
+	h.short = short
+
+	for len(h.shortsAt) > 0 && now.Sub(h.shortsAt[0]) > h.cfg.ShortWindow {
+		h.shortsAt = h.shortsAt[1:]
+	}
+	n := len(h.shortsAt)
+	if n >= h.cfg.MaxShorts {
+		h.set(AlarmRepeatedShorts, true, n, now)
+	} else if n == 0 {
+		h.set(AlarmRepeatedShorts, false, n, now)
+	}
+}
+
+func (h *HealthMonitor) set(k AlarmKind, active bool, value int, now time.Time) {
+	if h.active[k] == active {
+		return
+	}
+	h.active[k] = active
+
+	select {
+	case h.events <- Alarm{Kind: k, Active: active, Value: value, Time: now}:
+	default:
+	}
+}
+
+func defaultInt(v *int, def int) {
+	if *v <= 0 {
+		*v = def
+	}
+}
+
+func defaultDuration(v *time.Duration, def time.Duration) {
+	if *v <= 0 {
+		*v = def
+	}
+}
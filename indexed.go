@@ -0,0 +1,130 @@
+package z21
+
+import (
+	"context"
+	"sync"
+)
+
+const (
+	CV_INDEX_HIGH uint16 = 31
+	CV_INDEX_LOW  uint16 = 32
+
+	// CVs 257 to 512 are paged through CV31 and CV32.
+	FirstIndexedCV uint16 = 257
+	LastIndexedCV  uint16 = 512
+)
+
+// CVPage selects an index page through CV31 and CV32.
+type CVPage struct {
+	CV31 uint8 `json:"cv31"`
+	CV32 uint8 `json:"cv32"`
+}
+
+// IndexedProgrammer gives access to the indexed CVs 257 to 512 of a
+// decoder. It writes CV31 and CV32 only when the requested page differs
+// from the one last selected.
+type IndexedProgrammer struct {
+	CVProgrammer
+
+	mu      sync.Mutex
+	page    CVPage
+	known31 bool
+	known32 bool
+}
+
+// NewIndexedProgrammer wraps a programming track or POM programmer.
+func NewIndexedProgrammer(p CVProgrammer) *IndexedProgrammer {
+	if ip, ok := p.(*IndexedProgrammer); ok {
+		return ip
+	}
+	return &IndexedProgrammer{CVProgrammer: p}
+}
+
+// ReadIndexed reads a CV of the given index page.
+func (ip *IndexedProgrammer) ReadIndexed(ctx context.Context, page CVPage, cv uint16) (uint8, error) {
+	if cv < FirstIndexedCV || cv > LastIndexedCV {
+		return 0, ErrInvalidCV
+	}
+
+	ip.mu.Lock()
+	defer ip.mu.Unlock()
+
+	if err := ip.selectPage(ctx, &page); err != nil {
+		return 0, err
+	}
+	return ip.CVProgrammer.ReadCV(ctx, cv)
+}
+
+// WriteIndexed writes a CV of the given index page.
+func (ip *IndexedProgrammer) WriteIndexed(ctx context.Context, page CVPage, cv uint16, value uint8) error {
+	if cv < FirstIndexedCV || cv > LastIndexedCV {
+		return ErrInvalidCV
+	}
+
+	ip.mu.Lock()
+	defer ip.mu.Unlock()
+
+	if err := ip.selectPage(ctx, &page); err != nil {
+		return err
+	}
+	return ip.CVProgrammer.WriteCV(ctx, cv, value)
+}
+
+// ReadCV reads a CV, or a CV of the current index page.
+func (ip *IndexedProgrammer) ReadCV(ctx context.Context, cv uint16) (uint8, error) {
+	ip.mu.Lock()
+	defer ip.mu.Unlock()
+
+	return ip.CVProgrammer.ReadCV(ctx, cv)
+}
+
+// WriteCV writes a CV. Writes to CV31 and CV32 update the cached page.
+func (ip *IndexedProgrammer) WriteCV(ctx context.Context, cv uint16, value uint8) error {
+	ip.mu.Lock()
+	defer ip.mu.Unlock()
+
+	return ip.writeCV(ctx, cv, value)
+}
+
+// Invalidate forgets the cached page, e.g. after the decoder was reset or
+// swapped.
+func (ip *IndexedProgrammer) Invalidate() {
+	ip.mu.Lock()
+	defer ip.mu.Unlock()
+
+	ip.known31 = false
+	ip.known32 = false
+}
+
+// ---------- helpers ----------
+
+// selectPage writes the index CVs that differ from the requested page.
+func (ip *IndexedProgrammer) selectPage(ctx context.Context, page *CVPage) error {
+	if page == nil {
+		return nil
+	}
+	if !ip.known31 || ip.page.CV31 != page.CV31 {
+		if err := ip.writeCV(ctx, CV_INDEX_HIGH, page.CV31); err != nil {
+			return err
+		}
+	}
+	if !ip.known32 || ip.page.CV32 != page.CV32 {
+		if err := ip.writeCV(ctx, CV_INDEX_LOW, page.CV32); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ip *IndexedProgrammer) writeCV(ctx context.Context, cv uint16, value uint8) error {
+	err := ip.CVProgrammer.WriteCV(ctx, cv, value)
+	switch cv {
+	case CV_INDEX_HIGH:
+		ip.page.CV31 = value
+		ip.known31 = err == nil
+	case CV_INDEX_LOW:
+		ip.page.CV32 = value
+		ip.known32 = err == nil
+	}
+	return err
+}
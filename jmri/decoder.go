@@ -0,0 +1,102 @@
+package jmri
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/trains-io/z21.go"
+)
+
+// Decoder programs a decoder by variable name.
+type Decoder struct {
+	Definition *Definition
+	Programmer *z21.IndexedProgrammer
+}
+
+// NewDecoder binds a definition to a programming track or POM
+// programmer.
+func NewDecoder(d *Definition, p z21.CVProgrammer) *Decoder {
+	return &Decoder{
+		Definition: d,
+		Programmer: z21.NewIndexedProgrammer(p),
+	}
+}
+
+// Read returns the value of a variable.
+func (d *Decoder) Read(ctx context.Context, name string) (int, error) {
+	v, err := d.Definition.Variable(name)
+	if err != nil {
+		return 0, err
+	}
+	cv, err := d.readCV(ctx, v)
+	if err != nil {
+		return 0, err
+	}
+	return v.Decode(cv), nil
+}
+
+// Write sets the value of a variable. Bits of the CV outside the
+// variable mask are preserved.
+func (d *Decoder) Write(ctx context.Context, name string, value int) error {
+	v, err := d.Definition.Variable(name)
+	if err != nil {
+		return err
+	}
+	if value < v.Min || value > v.Max {
+		return fmt.Errorf("jmri: %s out of range %d..%d: %d", v.Name, v.Min, v.Max, value)
+	}
+
+	var cv uint8
+	if v.Mask != 0xFF {
+		if cv, err = d.readCV(ctx, v); err != nil {
+			return err
+		}
+	}
+	return d.writeCV(ctx, v, v.Encode(cv, value))
+}
+
+// ReadChoice returns the enum choice of a variable.
+func (d *Decoder) ReadChoice(ctx context.Context, name string) (string, error) {
+	v, err := d.Definition.Variable(name)
+	if err != nil {
+		return "", err
+	}
+	value, err := d.Read(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	c, ok := v.ChoiceName(value)
+	if !ok {
+		return "", fmt.Errorf("%w: %s = %d", ErrNoChoice, v.Name, value)
+	}
+	return c, nil
+}
+
+// WriteChoice sets an enum variable to the named choice.
+func (d *Decoder) WriteChoice(ctx context.Context, name, choice string) error {
+	v, err := d.Definition.Variable(name)
+	if err != nil {
+		return err
+	}
+	value, err := v.Choice(choice)
+	if err != nil {
+		return err
+	}
+	return d.Write(ctx, name, value)
+}
+
+// ---------- helpers ----------
+
+func (d *Decoder) readCV(ctx context.Context, v *Variable) (uint8, error) {
+	if v.Page != nil {
+		return d.Programmer.ReadIndexed(ctx, *v.Page, v.CV)
+	}
+	return d.Programmer.ReadCV(ctx, v.CV)
+}
+
+func (d *Decoder) writeCV(ctx context.Context, v *Variable, value uint8) error {
+	if v.Page != nil {
+		return d.Programmer.WriteIndexed(ctx, *v.Page, v.CV, value)
+	}
+	return d.Programmer.WriteCV(ctx, v.CV, value)
+}
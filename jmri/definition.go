@@ -0,0 +1,346 @@
+// Package jmri reads JMRI decoder definition files and uses them to
+// program decoder CVs by variable name.
+package jmri
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/trains-io/z21.go"
+)
+
+var (
+	ErrBadDefinition = errors.New("jmri: invalid decoder definition")
+	ErrNoVariable    = errors.New("jmri: unknown variable")
+	ErrNoChoice      = errors.New("jmri: unknown enum choice")
+)
+
+// Definition is a decoder family parsed from a JMRI decoder file.
+type Definition struct {
+	Family       string
+	Manufacturer string
+	LowVersion   uint8
+	HighVersion  uint8
+	Models       []Model
+	Variables    []Variable
+}
+
+// Model is a decoder model of a family. Version bounds of zero inherit
+// those of the family.
+type Model struct {
+	Name        string
+	LowVersion  uint8
+	HighVersion uint8
+}
+
+// Variable is a named field stored in the Mask bits of a single CV.
+type Variable struct {
+	Name    string
+	Label   string
+	CV      uint16
+	Page    *z21.CVPage
+	Mask    uint8
+	Min     int
+	Max     int
+	Default int
+	Choices []Choice
+}
+
+// Choice is a named value of an enum variable.
+type Choice struct {
+	Name  string
+	Value int
+}
+
+// ParseFile parses a JMRI decoder definition file.
+func ParseFile(path string) (*Definition, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	d, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return d, nil
+}
+
+// Parse parses a JMRI decoder definition. Variables spread over several
+// CVs and included variable files are skipped.
+func Parse(r io.Reader) (*Definition, error) {
+	var doc xmlDecoderConfig
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	fam := doc.Decoder.Family
+	if fam.Name == "" {
+		return nil, ErrBadDefinition
+	}
+
+	d := &Definition{
+		Family:       fam.Name,
+		Manufacturer: fam.Mfg,
+		LowVersion:   parseVersion(fam.LowVersionID),
+		HighVersion:  parseVersion(fam.HighVersionID),
+	}
+	for _, m := range fam.Models {
+		d.Models = append(d.Models, Model{
+			Name:        m.Model,
+			LowVersion:  parseVersion(m.LowVersionID),
+			HighVersion: parseVersion(m.HighVersionID),
+		})
+	}
+	for _, xv := range doc.Decoder.Variables.Variables {
+		v, ok := xv.variable()
+		if !ok {
+			continue
+		}
+		d.Variables = append(d.Variables, v)
+	}
+	return d, nil
+}
+
+// Variable looks up a variable by item name or label, ignoring case.
+func (d *Definition) Variable(name string) (*Variable, error) {
+	for i := range d.Variables {
+		v := &d.Variables[i]
+		if strings.EqualFold(v.Name, name) || strings.EqualFold(v.Label, name) {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", ErrNoVariable, name)
+}
+
+// Matches reports whether the definition covers a decoder with the given
+// manufacturer ID (CV8) and version (CV7).
+func (d *Definition) Matches(mfg, version uint8) bool {
+	if !sameManufacturer(d.Manufacturer, z21.LookupManufacturer(mfg).Name) {
+		return false
+	}
+	if !inVersion(version, d.LowVersion, d.HighVersion) {
+		return false
+	}
+	if len(d.Models) == 0 {
+		return true
+	}
+	for _, m := range d.Models {
+		if inVersion(version, m.LowVersion, m.HighVersion) {
+			return true
+		}
+	}
+	return false
+}
+
+// Decode extracts the variable value from a CV value.
+func (v *Variable) Decode(cv uint8) int {
+	return int(cv&v.Mask) >> bits.TrailingZeros8(v.Mask)
+}
+
+// Encode merges the variable value into a CV value.
+func (v *Variable) Encode(cv uint8, value int) uint8 {
+	return cv&^v.Mask | uint8(value<<bits.TrailingZeros8(v.Mask))&v.Mask
+}
+
+// Choice returns the value of an enum choice, ignoring case.
+func (v *Variable) Choice(name string) (int, error) {
+	for _, c := range v.Choices {
+		if strings.EqualFold(c.Name, name) {
+			return c.Value, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %q", ErrNoChoice, name)
+}
+
+// ChoiceName returns the name of an enum value.
+func (v *Variable) ChoiceName(value int) (string, bool) {
+	for _, c := range v.Choices {
+		if c.Value == value {
+			return c.Name, true
+		}
+	}
+	return "", false
+}
+
+// ---------- xml ----------
+
+type xmlDecoderConfig struct {
+	XMLName xml.Name   `xml:"decoder-config"`
+	Decoder xmlDecoder `xml:"decoder"`
+}
+
+type xmlDecoder struct {
+	Family    xmlFamily    `xml:"family"`
+	Variables xmlVariables `xml:"variables"`
+}
+
+type xmlFamily struct {
+	Name          string     `xml:"name,attr"`
+	Mfg           string     `xml:"mfg,attr"`
+	LowVersionID  string     `xml:"lowVersionID,attr"`
+	HighVersionID string     `xml:"highVersionID,attr"`
+	Models        []xmlModel `xml:"model"`
+}
+
+type xmlModel struct {
+	Model         string `xml:"model,attr"`
+	LowVersionID  string `xml:"lowVersionID,attr"`
+	HighVersionID string `xml:"highVersionID,attr"`
+}
+
+type xmlVariables struct {
+	Variables []xmlVariable `xml:"variable"`
+}
+
+type xmlVariable struct {
+	Item    string      `xml:"item,attr"`
+	CV      string      `xml:"CV,attr"`
+	Mask    string      `xml:"mask,attr"`
+	Default string      `xml:"default,attr"`
+	Labels  []string    `xml:"label"`
+	DecVal  *xmlDecVal  `xml:"decVal"`
+	EnumVal *xmlEnumVal `xml:"enumVal"`
+}
+
+type xmlDecVal struct {
+	Min string `xml:"min,attr"`
+	Max string `xml:"max,attr"`
+}
+
+type xmlEnumVal struct {
+	Choices []xmlEnumChoice `xml:"enumChoice"`
+}
+
+type xmlEnumChoice struct {
+	Choice string `xml:"choice,attr"`
+	Value  string `xml:"value,attr"`
+}
+
+// ---------- helpers ----------
+
+// variable converts a single CV variable; ok is false for variables this
+// package cannot program.
+func (xv xmlVariable) variable() (Variable, bool) {
+	cv, page, ok := parseCV(xv.CV)
+	if !ok || xv.Item == "" {
+		return Variable{}, false
+	}
+	mask, ok := parseMask(xv.Mask)
+	if !ok {
+		return Variable{}, false
+	}
+
+	v := Variable{
+		Name: xv.Item,
+		CV:   cv,
+		Page: page,
+		Mask: mask,
+		Max:  int(mask >> bits.TrailingZeros8(mask)),
+	}
+	if len(xv.Labels) > 0 {
+		v.Label = strings.TrimSpace(xv.Labels[0])
+	}
+	v.Default, _ = strconv.Atoi(xv.Default)
+	if xv.DecVal != nil {
+		if n, err := strconv.Atoi(xv.DecVal.Min); err == nil {
+			v.Min = n
+		}
+		if n, err := strconv.Atoi(xv.DecVal.Max); err == nil {
+			v.Max = n
+		}
+	}
+	if xv.EnumVal != nil {
+		next := 0
+		for _, c := range xv.EnumVal.Choices {
+			if n, err := strconv.Atoi(c.Value); err == nil {
+				next = n
+			}
+			v.Choices = append(v.Choices, Choice{Name: c.Choice, Value: next})
+			next++
+		}
+	}
+	return v, true
+}
+
+// parseCV parses a CV number, or an indexed CV written as CV31.CV32.CV.
+func parseCV(s string) (uint16, *z21.CVPage, bool) {
+	parts := strings.Split(strings.TrimSpace(s), ".")
+	switch len(parts) {
+	case 1:
+		cv, err := strconv.ParseUint(parts[0], 10, 16)
+		if err != nil || cv < 1 || uint16(cv) > z21.MaxCV {
+			return 0, nil, false
+		}
+		return uint16(cv), nil, true
+	case 3:
+		cv31, err1 := strconv.ParseUint(parts[0], 10, 8)
+		cv32, err2 := strconv.ParseUint(parts[1], 10, 8)
+		cv, err3 := strconv.ParseUint(parts[2], 10, 16)
+		if err1 != nil || err2 != nil || err3 != nil ||
+			uint16(cv) < z21.FirstIndexedCV || uint16(cv) > z21.LastIndexedCV {
+			return 0, nil, false
+		}
+		return uint16(cv), &z21.CVPage{CV31: uint8(cv31), CV32: uint8(cv32)}, true
+	default:
+		return 0, nil, false
+	}
+}
+
+// parseMask parses a JMRI bit mask such as "XXXXVVVV", most significant
+// bit first. An empty mask covers the whole CV.
+func parseMask(s string) (uint8, bool) {
+	if s == "" {
+		return 0xFF, true
+	}
+	if len(s) > 8 {
+		return 0, false
+	}
+	var mask uint8
+	for _, c := range s {
+		mask <<= 1
+		switch c {
+		case 'V':
+			mask |= 1
+		case 'X':
+		default:
+			return 0, false
+		}
+	}
+	return mask, mask != 0
+}
+
+func parseVersion(s string) uint8 {
+	n, err := strconv.ParseUint(s, 10, 8)
+	if err != nil {
+		return 0
+	}
+	return uint8(n)
+}
+
+// sameManufacturer compares a JMRI manufacturer name with an NMRA one.
+// JMRI often uses a shortened name, e.g. "Zimo" for "Zimo Elektronik".
+func sameManufacturer(jmri, nmra string) bool {
+	jmri, nmra = strings.ToLower(jmri), strings.ToLower(nmra)
+	if jmri == "" || nmra == "" {
+		return false
+	}
+	return strings.Contains(nmra, jmri) || strings.Contains(jmri, nmra)
+}
+
+func inVersion(version, low, high uint8) bool {
+	if low != 0 && version < low {
+		return false
+	}
+	if high != 0 && version > high {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,97 @@
+package jmri
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/trains-io/z21.go"
+)
+
+var (
+	ErrNoDefinition        = errors.New("jmri: no matching decoder definition")
+	ErrAmbiguousDefinition = errors.New("jmri: several decoder definitions match")
+)
+
+// AmbiguousError is returned by Identify when several definitions match
+// the decoder. Pick the right one from Definitions.
+type AmbiguousError struct {
+	Definitions []*Definition
+}
+
+func (e *AmbiguousError) Error() string {
+	families := make([]string, len(e.Definitions))
+	for i, d := range e.Definitions {
+		families[i] = d.Family
+	}
+	return fmt.Sprintf("%v: %s", ErrAmbiguousDefinition, strings.Join(families, ", "))
+}
+
+func (e *AmbiguousError) Unwrap() error {
+	return ErrAmbiguousDefinition
+}
+
+// Library is a set of decoder definitions loaded from disk.
+type Library struct {
+	Definitions []*Definition
+}
+
+// LoadDir parses all decoder definition files in a directory, such as
+// the xml/decoders directory of a JMRI installation. Files that are not
+// decoder definitions are ignored.
+func LoadDir(dir string) (*Library, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Library{}
+	for _, e := range entries {
+		if e.IsDir() || !strings.EqualFold(filepath.Ext(e.Name()), ".xml") {
+			continue
+		}
+		d, err := ParseFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			continue
+		}
+		l.Definitions = append(l.Definitions, d)
+	}
+	return l, nil
+}
+
+// Match returns the definitions covering a decoder with the given
+// manufacturer ID (CV8) and version (CV7).
+func (l *Library) Match(mfg, version uint8) []*Definition {
+	var defs []*Definition
+	for _, d := range l.Definitions {
+		if d.Matches(mfg, version) {
+			defs = append(defs, d)
+		}
+	}
+	return defs
+}
+
+// Identify reads CV7 and CV8 and returns the matching definition. If
+// several match, as is common for families without version bounds, it
+// returns an *AmbiguousError listing them.
+func (l *Library) Identify(ctx context.Context, p z21.CVProgrammer) (*Definition, error) {
+	version, err := p.ReadCV(ctx, z21.CV_VERSION)
+	if err != nil {
+		return nil, err
+	}
+	mfg, err := p.ReadCV(ctx, z21.CV_MANUFACTURER)
+	if err != nil {
+		return nil, err
+	}
+	defs := l.Match(mfg, version)
+	if len(defs) == 0 {
+		return nil, ErrNoDefinition
+	}
+	if len(defs) > 1 {
+		return nil, &AmbiguousError{Definitions: defs}
+	}
+	return defs[0], nil
+}
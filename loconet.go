@@ -0,0 +1,199 @@
+package z21
+
+import (
+	"context"
+	"encoding/binary"
+	"time"
+)
+
+const (
+	// queries
+	LOCONET_DETECTOR_QUERY_OCCUPANCY   uint8 = 0x80
+	LOCONET_DETECTOR_QUERY_TRANSPONDER uint8 = 0x81
+	LOCONET_DETECTOR_QUERY_LISSY       uint8 = 0x82
+
+	// reports
+	LOCONET_DETECTOR_OCCUPANCY         uint8 = 0x01
+	LOCONET_DETECTOR_TRANSPONDER_ENTER uint8 = 0x02
+	LOCONET_DETECTOR_TRANSPONDER_EXIT  uint8 = 0x03
+	LOCONET_DETECTOR_LISSY_ADDRESS     uint8 = 0x10
+	LOCONET_DETECTOR_LISSY_SPEED       uint8 = 0x12
+)
+
+// LAN_LOCONET_DETECTOR
+//
+// As a request, Type is one of the LOCONET_DETECTOR_QUERY_* types and
+// defaults to an occupancy query. Replies and broadcasts carry one of the
+// report types and are decoded into the typed messages below.
+type LocoNetDetector struct {
+	Type    uint8  `json:"type"`
+	Address uint16 `json:"address"`
+	Info    []byte `json:"info"`
+}
+
+// ---------- Message interface ----------
+
+func (m *LocoNetDetector) String() string {
+	return "loconet"
+}
+
+func (m *LocoNetDetector) Pack() ([]byte, error) {
+	typ := m.Type
+	if typ == 0 {
+		typ = LOCONET_DETECTOR_QUERY_OCCUPANCY
+	}
+	b := make([]byte, 3)
+	b[0] = typ
+	binary.LittleEndian.PutUint16(b[1:], m.Address)
+	return b, nil
+}
+
+func (m *LocoNetDetector) Unpack(data []byte) error {
+	if err := UnpackFields(data, &m.Type, &m.Address); err != nil {
+		return err
+	}
+	m.Info = append([]byte(nil), data[3:]...)
+	return nil
+}
+
+func (m *LocoNetDetector) EncapType() uint16 {
+	return LAN_LOCONET_DETECTOR
+}
+
+// ---------- Correlatable interface ----------
+
+func (m *LocoNetDetector) Key() (string, bool) {
+	return "", false
+}
+
+// LocoNetOccupancy reports the occupancy of a LocoNet feedback address.
+type LocoNetOccupancy struct {
+	LocoNetDetector
+	Occupied bool `json:"occupied"`
+}
+
+func (m *LocoNetOccupancy) Unpack(data []byte) error {
+	if err := m.LocoNetDetector.Unpack(data); err != nil {
+		return err
+	}
+	if len(m.Info) < 1 {
+		return ErrBadPacket
+	}
+	m.Occupied = m.Info[0] != 0
+	return nil
+}
+
+// LocoNetTransponder reports an Uhlenbrock transponder entering or
+// leaving a block.
+type LocoNetTransponder struct {
+	LocoNetDetector
+	Entered bool   `json:"entered"`
+	Loco    uint16 `json:"loco"`
+}
+
+func (m *LocoNetTransponder) Unpack(data []byte) error {
+	if err := m.LocoNetDetector.Unpack(data); err != nil {
+		return err
+	}
+	if len(m.Info) < 2 {
+		return ErrBadPacket
+	}
+	m.Entered = m.Type == LOCONET_DETECTOR_TRANSPONDER_ENTER
+	m.Loco = binary.LittleEndian.Uint16(m.Info)
+	return nil
+}
+
+// LissyLoco reports the loco seen by a Lissy sensor. Class is the
+// Lissy category of the loco (bits 0-3 of the third info byte) and
+// Forward its direction (bit 5).
+type LissyLoco struct {
+	LocoNetDetector
+	Loco    uint16 `json:"loco"`
+	Class   uint8  `json:"class"`
+	Forward bool   `json:"forward"`
+}
+
+func (m *LissyLoco) Unpack(data []byte) error {
+	if err := m.LocoNetDetector.Unpack(data); err != nil {
+		return err
+	}
+	if len(m.Info) < 3 {
+		return ErrBadPacket
+	}
+	m.Loco = binary.LittleEndian.Uint16(m.Info)
+	m.Class = m.Info[2] & 0x0F
+	m.Forward = m.Info[2]&0x20 != 0
+	return nil
+}
+
+// LissySpeed reports the speed measured by a Lissy sensor.
+type LissySpeed struct {
+	LocoNetDetector
+	Speed uint16 `json:"speed"`
+}
+
+func (m *LissySpeed) Unpack(data []byte) error {
+	if err := m.LocoNetDetector.Unpack(data); err != nil {
+		return err
+	}
+	if len(m.Info) < 2 {
+		return ErrBadPacket
+	}
+	m.Speed = binary.LittleEndian.Uint16(m.Info)
+	return nil
+}
+
+// LocoNetOccupied queries the occupancy of a LocoNet feedback address.
+// Detector reports are only sent to clients subscribed to
+// LOCONET_DETECTOR_UPDATES; without a report it returns ErrTimeout.
+func (nc *Conn) LocoNetOccupied(ctx context.Context, addr uint16) (bool, error) {
+	ch := make(chan bool, 1)
+	cancel := nc.watch(func(m Serializable) {
+		if occ, ok := m.(*LocoNetOccupancy); ok && occ.Address == addr {
+			select {
+			case ch <- occ.Occupied:
+			default:
+			}
+		}
+	})
+	defer cancel()
+
+	q := &LocoNetDetector{Type: LOCONET_DETECTOR_QUERY_OCCUPANCY, Address: addr}
+	if _, err := nc.SendRcv(ctx, q); err != nil {
+		return false, err
+	}
+
+	timer := time.NewTimer(nc.Opts.Timeout)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false, ctx.Err()
+	case <-timer.C:
+		return false, ErrTimeout
+	case occupied := <-ch:
+		return occupied, nil
+	}
+}
+
+// ---------- helpers ----------
+
+// decodeLocoNetDetector picks the typed message for a
+// LAN_LOCONET_DETECTOR frame from its type byte.
+func decodeLocoNetDetector(p []byte) Serializable {
+	if len(p) < 1 {
+		return &LocoNetDetector{}
+	}
+	switch p[0] {
+	case LOCONET_DETECTOR_OCCUPANCY:
+		return &LocoNetOccupancy{}
+	case LOCONET_DETECTOR_TRANSPONDER_ENTER, LOCONET_DETECTOR_TRANSPONDER_EXIT:
+		return &LocoNetTransponder{}
+	case LOCONET_DETECTOR_LISSY_ADDRESS:
+		return &LissyLoco{}
+	case LOCONET_DETECTOR_LISSY_SPEED:
+		return &LissySpeed{}
+	default:
+		return &LocoNetDetector{}
+	}
+}
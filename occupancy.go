@@ -0,0 +1,240 @@
+package z21
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type SensorSource uint8
+
+const (
+	SensorRBus SensorSource = iota
+	SensorCan
+	SensorLocoNet
+)
+
+// Sensor identifies a detector input on any bus. For R-BUS, Module is
+// the module number (1-20) and Port the input (1-8); for CAN, NetworkID,
+// Module and Port are the detector's network ID, module address and
+// port; for LocoNet, Module is the feedback address.
+type Sensor struct {
+	Source    SensorSource
+	NetworkID uint16
+	Module    uint16
+	Port      uint8
+}
+
+func RBusSensor(module uint16, input uint8) Sensor {
+	return Sensor{Source: SensorRBus, Module: module, Port: input}
+}
+
+func CanSensor(nid, addr uint16, port uint8) Sensor {
+	return Sensor{Source: SensorCan, NetworkID: nid, Module: addr, Port: port}
+}
+
+func LocoNetSensor(addr uint16) Sensor {
+	return Sensor{Source: SensorLocoNet, Module: addr}
+}
+
+// BlockEvent is either BlockOccupied or BlockFreed.
+type BlockEvent interface {
+	BlockName() string
+}
+
+type BlockOccupied struct {
+	Block  string
+	Sensor Sensor
+	Time   time.Time
+}
+
+type BlockFreed struct {
+	Block string
+	Time  time.Time
+}
+
+func (e BlockOccupied) BlockName() string { return e.Block }
+func (e BlockFreed) BlockName() string    { return e.Block }
+
+// Occupancy maps named blocks to detector inputs. A block is occupied
+// while any of its sensors is.
+type Occupancy struct {
+	mu      sync.Mutex
+	blocks  map[string][]Sensor
+	sensors map[Sensor][]string
+	state   map[Sensor]bool
+	events  chan BlockEvent
+}
+
+func NewOccupancy() *Occupancy {
+	return &Occupancy{
+		blocks:  make(map[string][]Sensor),
+		sensors: make(map[Sensor][]string),
+		state:   make(map[Sensor]bool),
+		events:  make(chan BlockEvent, defaultEventBufSize),
+	}
+}
+
+// AddBlock adds a block fed by the given sensors. A sensor may belong to
+// several blocks. Adding a block again replaces its sensors; sensors
+// given twice are added once.
+func (o *Occupancy) AddBlock(name string, sensors ...Sensor) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.removeBlock(name)
+
+	var list []Sensor
+	seen := make(map[Sensor]bool, len(sensors))
+	for _, s := range sensors {
+		if seen[s] {
+			continue
+		}
+		seen[s] = true
+		list = append(list, s)
+		o.sensors[s] = append(o.sensors[s], name)
+	}
+	o.blocks[name] = list
+}
+
+// Events returns block changes. Events are dropped if the channel is not
+// drained.
+func (o *Occupancy) Events() <-chan BlockEvent {
+	return o.events
+}
+
+// Occupied reports whether a block is occupied.
+func (o *Occupancy) Occupied(block string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.occupied(block)
+}
+
+// Snapshot returns the occupied state of every block.
+func (o *Occupancy) Snapshot() map[string]bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	snap := make(map[string]bool, len(o.blocks))
+	for name := range o.blocks {
+		snap[name] = o.occupied(name)
+	}
+	return snap
+}
+
+// Blocks returns the block names in order.
+func (o *Occupancy) Blocks() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	names := make([]string, 0, len(o.blocks))
+	for name := range o.blocks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Update applies a detector message from any bus: *RBusFeedback,
+// *CanOccupancy or *LocoNetOccupancy. Other messages are ignored.
+func (o *Occupancy) Update(m Serializable) {
+	switch m := m.(type) {
+	case *RBusFeedback:
+		base := uint16(m.Group) * RBusModulesPerGroup
+		for i, mod := range m.Modules {
+			for input := uint8(1); input <= RBusInputsPerModule; input++ {
+				o.Set(RBusSensor(base+uint16(i)+1, input), mod.Has(1<<(input-1)))
+			}
+		}
+	case *CanOccupancy:
+		o.Set(CanSensor(m.NetworkID, m.Address, m.Port), m.Occupied())
+	case *LocoNetOccupancy:
+		o.Set(LocoNetSensor(m.Address), m.Occupied)
+	}
+}
+
+// Set changes the state of a sensor and emits events for the blocks
+// whose state changed.
+func (o *Occupancy) Set(s Sensor, occupied bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	names, ok := o.sensors[s]
+	if !ok || o.state[s] == occupied {
+		return
+	}
+
+	before := make([]bool, len(names))
+	for i, name := range names {
+		before[i] = o.occupied(name)
+	}
+	o.state[s] = occupied
+
+	now := time.Now()
+	for i, name := range names {
+		after := o.occupied(name)
+		if after == before[i] {
+			continue
+		}
+		var e BlockEvent = BlockFreed{Block: name, Time: now}
+		if after {
+			e = BlockOccupied{Block: name, Sensor: s, Time: now}
+		}
+		select {
+		case o.events <- e:
+		default:
+		}
+	}
+}
+
+// TrackOccupancy feeds all detector messages delivered on Events() into
+// o until the returned cancel function is called.
+func (nc *Conn) TrackOccupancy(o *Occupancy) (cancel func()) {
+	return nc.watchEvents(o.Update)
+}
+
+// ---------- helpers ----------
+
+// blockSensors returns the sensors of a block.
+func (o *Occupancy) blockSensors(block string) []Sensor {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return append([]Sensor(nil), o.blocks[block]...)
+}
+
+// sensorBlocks returns the blocks a sensor belongs to.
+func (o *Occupancy) sensorBlocks(s Sensor) []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return append([]string(nil), o.sensors[s]...)
+}
+
+// removeBlock drops a block from the sensors it was mapped to.
+func (o *Occupancy) removeBlock(block string) {
+	for _, s := range o.blocks[block] {
+		names := o.sensors[s][:0]
+		for _, name := range o.sensors[s] {
+			if name != block {
+				names = append(names, name)
+			}
+		}
+		if len(names) == 0 {
+			delete(o.sensors, s)
+		} else {
+			o.sensors[s] = names
+		}
+	}
+	delete(o.blocks, block)
+}
+
+func (o *Occupancy) occupied(block string) bool {
+	for _, s := range o.blocks[block] {
+		if o.state[s] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,322 @@
+package z21
+
+import (
+	"context"
+	"errors"
+)
+
+const (
+	MaxCV          uint16 = 1024
+	MaxLocoAddress uint16 = 10239
+
+	MaxAccessoryDecoderAddress uint16 = 511
+)
+
+var (
+	ErrInvalidCV          = errors.New("z21: invalid CV number")
+	ErrInvalidCVBit       = errors.New("z21: invalid CV bit position")
+	ErrInvalidLocoAddress = errors.New("z21: invalid loco address")
+	ErrNoRailComAnswer    = errors.New("z21: no RailCom answer")
+
+	ErrInvalidAccessoryAddress = errors.New("z21: invalid accessory decoder address")
+	ErrInvalidAccessoryOutput  = errors.New("z21: invalid accessory decoder output")
+)
+
+// LAN_X_CV_POM_WRITE_BYTE
+type PomWriteByte struct {
+	Address uint16
+	CV      uint16
+	Value   uint8
+}
+
+// ---------- Message interface ----------
+
+func (m *PomWriteByte) Pack() ([]byte, error) {
+	addr, err := encodePomLocoAddress(m.Address)
+	if err != nil {
+		return nil, err
+	}
+	return packPom(LAN_X_E6_30, addr, LAN_X_CV_POM_WRITE_BYTE, m.CV, m.Value)
+}
+
+func (m *PomWriteByte) Unpack(data []byte) error {
+	return nil
+}
+
+func (m *PomWriteByte) EncapType() uint16 {
+	return LAN_X
+}
+
+// ---------- Correlatable interface ----------
+
+func (m *PomWriteByte) Key() (string, bool) {
+	return "", false
+}
+
+// LAN_X_CV_POM_WRITE_BIT
+type PomWriteBit struct {
+	Address uint16
+	CV      uint16
+	Bit     uint8
+	Value   bool
+}
+
+// ---------- Message interface ----------
+
+func (m *PomWriteBit) Pack() ([]byte, error) {
+	addr, err := encodePomLocoAddress(m.Address)
+	if err != nil {
+		return nil, err
+	}
+	data, err := encodePomBit(m.Bit, m.Value)
+	if err != nil {
+		return nil, err
+	}
+	return packPom(LAN_X_E6_30, addr, LAN_X_CV_POM_WRITE_BIT, m.CV, data)
+}
+
+func (m *PomWriteBit) Unpack(data []byte) error {
+	return nil
+}
+
+func (m *PomWriteBit) EncapType() uint16 {
+	return LAN_X
+}
+
+// ---------- Correlatable interface ----------
+
+func (m *PomWriteBit) Key() (string, bool) {
+	return "", false
+}
+
+// LAN_X_CV_POM_READ_BYTE
+//
+// The decoder answers through RailCom and the Z21 reports the value with
+// LAN_X_CV_RESULT.
+type PomReadByte struct {
+	Address uint16
+	CV      uint16
+}
+
+// ---------- Message interface ----------
+
+func (m *PomReadByte) Pack() ([]byte, error) {
+	addr, err := encodePomLocoAddress(m.Address)
+	if err != nil {
+		return nil, err
+	}
+	return packPom(LAN_X_E6_30, addr, LAN_X_CV_POM_READ_BYTE, m.CV, 0x00)
+}
+
+func (m *PomReadByte) Unpack(data []byte) error {
+	return nil
+}
+
+func (m *PomReadByte) EncapType() uint16 {
+	return LAN_X
+}
+
+// ---------- Correlatable interface ----------
+
+func (m *PomReadByte) Key() (string, bool) {
+	return cvResultKey()
+}
+
+// PomRead reads a CV of a loco decoder on the main track. It returns
+// ErrNoRailComAnswer if the decoder did not answer.
+func (nc *Conn) PomRead(ctx context.Context, addr, cv uint16) (uint8, error) {
+	var v uint8
+	err := nc.programming(ctx, func(CVProgrammer) error {
+		var err error
+		v, err = nc.pomRead(ctx, &PomReadByte{Address: addr, CV: cv}, cv)
+		return err
+	})
+	return v, err
+}
+
+// AccessoryPom selects an accessory decoder for POM programming. The
+// whole decoder is addressed unless ByOutput is set, in which case only
+// Output (0-7) is.
+type AccessoryPom struct {
+	Address  uint16
+	Output   uint8
+	ByOutput bool
+}
+
+// LAN_X_CV_POM_ACCESSORY_WRITE_BYTE
+type PomAccessoryWriteByte struct {
+	AccessoryPom
+	CV    uint16
+	Value uint8
+}
+
+// ---------- Message interface ----------
+
+func (m *PomAccessoryWriteByte) Pack() ([]byte, error) {
+	addr, err := m.encode()
+	if err != nil {
+		return nil, err
+	}
+	return packPom(LAN_X_E6_31, addr, LAN_X_CV_POM_ACCESSORY_WRITE_BYTE, m.CV, m.Value)
+}
+
+func (m *PomAccessoryWriteByte) Unpack(data []byte) error {
+	return nil
+}
+
+func (m *PomAccessoryWriteByte) EncapType() uint16 {
+	return LAN_X
+}
+
+// ---------- Correlatable interface ----------
+
+func (m *PomAccessoryWriteByte) Key() (string, bool) {
+	return "", false
+}
+
+// LAN_X_CV_POM_ACCESSORY_WRITE_BIT
+type PomAccessoryWriteBit struct {
+	AccessoryPom
+	CV    uint16
+	Bit   uint8
+	Value bool
+}
+
+// ---------- Message interface ----------
+
+func (m *PomAccessoryWriteBit) Pack() ([]byte, error) {
+	addr, err := m.encode()
+	if err != nil {
+		return nil, err
+	}
+	data, err := encodePomBit(m.Bit, m.Value)
+	if err != nil {
+		return nil, err
+	}
+	return packPom(LAN_X_E6_31, addr, LAN_X_CV_POM_ACCESSORY_WRITE_BIT, m.CV, data)
+}
+
+func (m *PomAccessoryWriteBit) Unpack(data []byte) error {
+	return nil
+}
+
+func (m *PomAccessoryWriteBit) EncapType() uint16 {
+	return LAN_X
+}
+
+// ---------- Correlatable interface ----------
+
+func (m *PomAccessoryWriteBit) Key() (string, bool) {
+	return "", false
+}
+
+// LAN_X_CV_POM_ACCESSORY_READ_BYTE
+type PomAccessoryReadByte struct {
+	AccessoryPom
+	CV uint16
+}
+
+// ---------- Message interface ----------
+
+func (m *PomAccessoryReadByte) Pack() ([]byte, error) {
+	addr, err := m.encode()
+	if err != nil {
+		return nil, err
+	}
+	return packPom(LAN_X_E6_31, addr, LAN_X_CV_POM_ACCESSORY_READ_BYTE, m.CV, 0x00)
+}
+
+func (m *PomAccessoryReadByte) Unpack(data []byte) error {
+	return nil
+}
+
+func (m *PomAccessoryReadByte) EncapType() uint16 {
+	return LAN_X
+}
+
+// ---------- Correlatable interface ----------
+
+func (m *PomAccessoryReadByte) Key() (string, bool) {
+	return cvResultKey()
+}
+
+// PomAccessoryRead reads a CV of an accessory decoder on the main track.
+// It returns ErrNoRailComAnswer if the decoder did not answer.
+func (nc *Conn) PomAccessoryRead(ctx context.Context, acc AccessoryPom, cv uint16) (uint8, error) {
+	var v uint8
+	err := nc.programming(ctx, func(CVProgrammer) error {
+		var err error
+		v, err = nc.pomRead(ctx, &PomAccessoryReadByte{AccessoryPom: acc, CV: cv}, cv)
+		return err
+	})
+	return v, err
+}
+
+// ---------- helpers ----------
+
+// encode returns the POM address word aaaaaaaaaCDDD of an accessory
+// decoder, where C selects a single output DDD.
+func (a AccessoryPom) encode() (uint16, error) {
+	if a.Address > MaxAccessoryDecoderAddress {
+		return 0, ErrInvalidAccessoryAddress
+	}
+	addr := a.Address << 4
+	if a.ByOutput {
+		if a.Output > 7 {
+			return 0, ErrInvalidAccessoryOutput
+		}
+		addr |= 0x08 | uint16(a.Output)
+	}
+	return addr, nil
+}
+
+func (nc *Conn) pomRead(ctx context.Context, m Serializable, cv uint16) (uint8, error) {
+	// The Z21 reports a missing RailCom answer as LAN_X_CV_NACK.
+	resp, err := nc.SendRcv(ctx, m)
+	if errors.Is(err, ErrTimeout) || errors.Is(err, ErrCvNack) {
+		return 0, ErrNoRailComAnswer
+	}
+	if err != nil {
+		return 0, err
+	}
+	return cvValue(resp, cv)
+}
+
+// encodePomLocoAddress returns the POM address word of a loco decoder.
+// Unlike LAN_X_GET_LOCO_INFO, POM does not flag long addresses in the
+// two upper bits.
+func encodePomLocoAddress(addr uint16) (uint16, error) {
+	if addr < 1 || addr > MaxLocoAddress {
+		return 0, ErrInvalidLocoAddress
+	}
+	return addr & 0x3FFF, nil
+}
+
+// encodePomBit returns the 0000VPPP data byte of a bit write.
+func encodePomBit(bit uint8, value bool) (uint8, error) {
+	if bit > 7 {
+		return 0, ErrInvalidCVBit
+	}
+	if value {
+		bit |= 0x08
+	}
+	return bit, nil
+}
+
+// packPom builds a LAN_X_E6 frame payload. The two upper bits of the CV
+// address are packed into the option byte.
+func packPom(db0 uint8, addr uint16, option uint8, cv uint16, value uint8) ([]byte, error) {
+	cvAdr, err := encodeCV(cv)
+	if err != nil {
+		return nil, err
+	}
+	return packXBus(
+		LAN_X_E6,
+		db0,
+		byte(addr>>8),
+		byte(addr),
+		option|byte(cvAdr>>8)&0x03,
+		byte(cvAdr),
+		value,
+	), nil
+}
@@ -0,0 +1,149 @@
+package z21
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+// xbus appends the X-Bus checksum to data.
+func xbus(data ...byte) []byte {
+	var x byte
+	for _, b := range data {
+		x ^= b
+	}
+	return append(data, x)
+}
+
+func TestPomPack(t *testing.T) {
+	tests := []struct {
+		name  string
+		m     Serializable
+		want  []byte
+		frame string
+		err   error
+	}{
+		{
+			name:  "write byte",
+			m:     &PomWriteByte{Address: 3, CV: 1, Value: 5},
+			want:  xbus(0xE6, 0x30, 0x00, 0x03, 0xEC, 0x00, 0x05),
+			frame: "LAN_X_CV_POM_WRITE_BYTE",
+		},
+		{
+			name:  "write byte long address CV 1024",
+			m:     &PomWriteByte{Address: 10239, CV: 1024, Value: 0xAA},
+			want:  xbus(0xE6, 0x30, 0x27, 0xFF, 0xEF, 0xFF, 0xAA),
+			frame: "LAN_X_CV_POM_WRITE_BYTE",
+		},
+		{
+			name:  "write byte CV 257",
+			m:     &PomWriteByte{Address: 3, CV: 257, Value: 1},
+			want:  xbus(0xE6, 0x30, 0x00, 0x03, 0xED, 0x00, 0x01),
+			frame: "LAN_X_CV_POM_WRITE_BYTE",
+		},
+		{
+			name:  "write bit CV 300",
+			m:     &PomWriteBit{Address: 3, CV: 300, Bit: 7, Value: true},
+			want:  xbus(0xE6, 0x30, 0x00, 0x03, 0xE9, 0x2B, 0x0F),
+			frame: "LAN_X_CV_POM_WRITE_BIT",
+		},
+		{
+			name:  "read byte CV 513",
+			m:     &PomReadByte{Address: 3, CV: 513},
+			want:  xbus(0xE6, 0x30, 0x00, 0x03, 0xE6, 0x00, 0x00),
+			frame: "LAN_X_CV_POM_READ_BYTE",
+		},
+		{
+			name: "accessory write byte to output",
+			m: &PomAccessoryWriteByte{
+				AccessoryPom: AccessoryPom{Address: 5, Output: 3, ByOutput: true},
+				CV:           260,
+				Value:        9,
+			},
+			want:  xbus(0xE6, 0x31, 0x00, 0x5B, 0xED, 0x03, 0x09),
+			frame: "LAN_X_CV_POM_ACCESSORY_WRITE_BYTE",
+		},
+		{
+			name: "accessory write bit",
+			m: &PomAccessoryWriteBit{
+				AccessoryPom: AccessoryPom{Address: 1},
+				CV:           2,
+				Bit:          0,
+			},
+			want:  xbus(0xE6, 0x31, 0x00, 0x10, 0xE8, 0x01, 0x00),
+			frame: "LAN_X_CV_POM_ACCESSORY_WRITE_BIT",
+		},
+		{
+			name:  "accessory read whole decoder",
+			m:     &PomAccessoryReadByte{AccessoryPom: AccessoryPom{Address: 511}, CV: 1},
+			want:  xbus(0xE6, 0x31, 0x1F, 0xF0, 0xE4, 0x00, 0x00),
+			frame: "LAN_X_CV_POM_ACCESSORY_READ_BYTE",
+		},
+		{name: "address 0", m: &PomWriteByte{Address: 0, CV: 1}, err: ErrInvalidLocoAddress},
+		{name: "address too high", m: &PomReadByte{Address: 10240, CV: 1}, err: ErrInvalidLocoAddress},
+		{name: "CV 0", m: &PomWriteByte{Address: 3, CV: 0}, err: ErrInvalidCV},
+		{name: "CV too high", m: &PomReadByte{Address: 3, CV: 1025}, err: ErrInvalidCV},
+		{name: "bit too high", m: &PomWriteBit{Address: 3, CV: 1, Bit: 8}, err: ErrInvalidCVBit},
+		{
+			name: "accessory address too high",
+			m:    &PomAccessoryReadByte{AccessoryPom: AccessoryPom{Address: 512}, CV: 1},
+			err:  ErrInvalidAccessoryAddress,
+		},
+		{
+			name: "accessory output too high",
+			m:    &PomAccessoryReadByte{AccessoryPom: AccessoryPom{Address: 1, Output: 8, ByOutput: true}, CV: 1},
+			err:  ErrInvalidAccessoryOutput,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.m.Pack()
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Pack() error = %v, want %v", err, tt.err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("Pack() = % X, want % X", got, tt.want)
+			}
+			if tt.err != nil {
+				return
+			}
+			frame, err := WrapMessage(tt.m)
+			if err != nil {
+				t.Fatalf("WrapMessage() error = %v", err)
+			}
+			if name := frame.Name(); name != tt.frame {
+				t.Errorf("Name() = %q, want %q", name, tt.frame)
+			}
+		})
+	}
+}
+
+func TestPomRead(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply []Serializable
+		want  uint8
+		err   error
+	}{
+		{name: "value", reply: []Serializable{&CvResult{CV: 300, Value: 42}}, want: 42},
+		{name: "no answer", reply: nil, err: ErrNoRailComAnswer},
+		{name: "nack", reply: []Serializable{&CvNack{}}, err: ErrNoRailComAnswer},
+		{name: "short circuit", reply: []Serializable{&CvNack{ShortCircuit: true}}, err: ErrCvNackShortCircuit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nc := connectFake(t, func(Frame) []Serializable { return tt.reply })
+
+			got, err := nc.PomRead(context.Background(), 3, 300)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("PomRead() error = %v, want %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("PomRead() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package z21
+
+// LAN_X_BC_PROGRAMMING_MODE
+type ProgrammingMode struct{}
+
+// ---------- Message interface ----------
+
+func (m *ProgrammingMode) String() string {
+	return "programming mode"
+}
+
+func (m *ProgrammingMode) Pack() ([]byte, error) {
+	return packXBus(LAN_X_61, LAN_X_BC_PROGRAMMING_MODE), nil
+}
+
+func (m *ProgrammingMode) Unpack(data []byte) error {
+	return nil
+}
+
+func (m *ProgrammingMode) EncapType() uint16 {
+	return LAN_X
+}
+
+// ---------- Correlatable interface ----------
+
+func (m *ProgrammingMode) Key() (string, bool) {
+	return "", false
+}
@@ -0,0 +1,30 @@
+package z21
+
+import "context"
+
+// CVProgrammer reads and writes the CVs of a single decoder.
+//
+// *Conn programs the decoder on the programming track; PomProgrammer
+// programs a loco decoder on the main track.
+type CVProgrammer interface {
+	ReadCV(ctx context.Context, cv uint16) (uint8, error)
+	WriteCV(ctx context.Context, cv uint16, value uint8) error
+}
+
+// PomProgrammer programs the loco decoder with the given address on the
+// main track. Reads require a RailCom capable decoder.
+type PomProgrammer struct {
+	Conn    *Conn
+	Address uint16
+}
+
+func (p *PomProgrammer) ReadCV(ctx context.Context, cv uint16) (uint8, error) {
+	return p.Conn.PomRead(ctx, p.Address, cv)
+}
+
+// WriteCV writes a CV on the main track. POM writes are not acknowledged
+// by the decoder, so success only means the command was sent.
+func (p *PomProgrammer) WriteCV(ctx context.Context, cv uint16, value uint8) error {
+	_, err := p.Conn.program(ctx, &PomWriteByte{Address: p.Address, CV: cv, Value: value})
+	return err
+}
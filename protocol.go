@@ -26,6 +26,12 @@ type Correlatable interface {
 	Key() (string, bool)
 }
 
+// Rejection is implemented by replies that report the failure of the
+// request they correlate with instead of carrying its result.
+type Rejection interface {
+	Reject() error
+}
+
 type Serializable interface {
 	Message
 	Correlatable
@@ -71,8 +77,6 @@ func (f *Frame) Name() string {
 	case LAN_X:
 		xHeader := f.Payload[0]
 		switch xHeader {
-		case LAN_X_GET_FIRMWARE_VERSION:
-			return "LAN_X_GET_FIRMWARE_VERSION"
 		case LAN_X_21:
 			db0 := f.Payload[1]
 			switch db0 {
@@ -185,7 +189,8 @@ func (f *Frame) Name() string {
 			db0 := f.Payload[1]
 			switch db0 {
 			case LAN_X_E6_30:
-				db3 := f.Payload[4]
+				// The two low bits carry the CV address MSB.
+				db3 := f.Payload[4] & 0xFC
 				switch db3 {
 				case LAN_X_CV_POM_WRITE_BYTE:
 					return "LAN_X_CV_POM_WRITE_BYTE"
@@ -197,7 +202,8 @@ func (f *Frame) Name() string {
 					return fmt.Sprintf("UNKNOWN DB3: (%02x)", db3)
 				}
 			case LAN_X_E6_31:
-				db3 := f.Payload[4]
+				// The two low bits carry the CV address MSB.
+				db3 := f.Payload[4] & 0xFC
 				switch db3 {
 				case LAN_X_CV_POM_ACCESSORY_WRITE_BYTE:
 					return "LAN_X_CV_POM_ACCESSORY_WRITE_BYTE"
@@ -213,7 +219,7 @@ func (f *Frame) Name() string {
 			}
 		case LAN_X_LOCO_INFO:
 			return "LAN_X_LOCO_INFO"
-		case LAN_X_F3:
+		case LAN_X_F1, LAN_X_F3:
 			db0 := f.Payload[1]
 			switch db0 {
 			case LAN_X_GET_FIRMWARE_VERSION:
@@ -395,8 +401,14 @@ func DecodeFrame(f Frame) (Serializable, error) {
 		m = &SubscribedBroadcastFlags{}
 	case LAN_SYSTEMSTATE_DATACHANGED:
 		m = &SysData{}
+	case LAN_RMBUS_DATACHANGED:
+		m = &RBusFeedback{}
+	case LAN_RAILCOM_DATACHANGED:
+		m = &RailComData{}
+	case LAN_LOCONET_DETECTOR:
+		m = decodeLocoNetDetector(f.Payload)
 	case LAN_CAN_DETECTOR:
-		m = &CanDetector{}
+		m = decodeCanDetector(f.Payload)
 	default:
 		return nil, fmt.Errorf("unknown frame header %d", f.Header)
 	}
@@ -421,6 +433,16 @@ func DecodeXHeader(p []byte) (Serializable, error) {
 		m = &Stop{}
 	case LAN_X_LOCO_INFO:
 		m = &LocoInfo{}
+	case LAN_X_CV_RESULT:
+		m = &CvResult{}
+	case LAN_X_F3:
+		if len(p) < 2 {
+			return nil, ErrBadPacket
+		}
+		if p[1] != LAN_X_GET_FIRMWARE_VERSION {
+			return nil, fmt.Errorf("unknown x-bus f3 db0 %d", p[1])
+		}
+		m = &FirmwareVersion{}
 	default:
 		return nil, fmt.Errorf("unknown x-bus header %d", xhdr)
 	}
@@ -437,6 +459,14 @@ func DecodeDB0(p []byte) (Serializable, error) {
 		m = &TrackPower{}
 	case LAN_X_GET_VERSION:
 		m = &Version{}
+	case LAN_X_CV_NACK, LAN_X_CV_NACK_SC:
+		m = &CvNack{}
+	case LAN_X_BC_PROGRAMMING_MODE:
+		m = &ProgrammingMode{}
+	case LAN_X_BC_TRACK_SHORT_CIRCUIT:
+		m = &TrackShortCircuit{}
+	case LAN_X_UNKNOWN_COMMAND:
+		m = &UnknownCommand{}
 	default:
 		return nil, fmt.Errorf("unknown x-bus db0 %d", db0)
 	}
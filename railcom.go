@@ -0,0 +1,154 @@
+package z21
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	RAILCOM_POLL_ADDRESS uint8 = 0x01
+
+	RC_SPEED1 uint8 = 0x01 // bit 0
+	RC_SPEED2 uint8 = 0x02 // bit 1
+	RC_QOS    uint8 = 0x04 // bit 2
+)
+
+// LAN_RAILCOM_GETDATA
+//
+// Address 0 polls the next loco from the Z21's RailCom ring buffer. That
+// reply cannot be correlated and is delivered on Events() instead.
+type RailComGetData struct {
+	Address uint16
+}
+
+// ---------- Message interface ----------
+
+func (m *RailComGetData) Pack() ([]byte, error) {
+	b := make([]byte, 3)
+	b[0] = RAILCOM_POLL_ADDRESS
+	binary.LittleEndian.PutUint16(b[1:], m.Address)
+	return b, nil
+}
+
+func (m *RailComGetData) Unpack(data []byte) error {
+	return nil
+}
+
+func (m *RailComGetData) EncapType() uint16 {
+	return LAN_RAILCOM_GETDATA
+}
+
+// ---------- Correlatable interface ----------
+
+func (m *RailComGetData) Key() (string, bool) {
+	if m.Address == 0 {
+		return "", false
+	}
+	return railComKey(m.Address)
+}
+
+// LAN_RAILCOM_DATACHANGED
+//
+// Broadcasts are sent for all locos to clients subscribed to
+// RAILCOM_UPDATES, and for locos the client controls to clients
+// subscribed to RAILCOM_SUB_UPDATES.
+type RailComData struct {
+	Address        uint16 `json:"address"`
+	ReceiveCounter uint32 `json:"receive_counter"`
+	ErrorCounter   uint16 `json:"error_counter"`
+	Reserved1      uint8  `json:"-"`
+	Options        Mask8  `json:"options"`
+	Speed          uint8  `json:"speed"`
+	QoS            uint8  `json:"qos"`
+	Reserved2      uint8  `json:"-"`
+}
+
+// ---------- Message interface ----------
+
+func (m *RailComData) String() string {
+	return "railcom"
+}
+
+func (m *RailComData) Pack() ([]byte, error) {
+	return PackFields(
+		m.Address,
+		m.ReceiveCounter,
+		m.ErrorCounter,
+		m.Reserved1,
+		m.Options,
+		m.Speed,
+		m.QoS,
+		m.Reserved2,
+	)
+}
+
+func (m *RailComData) Unpack(data []byte) error {
+	return UnpackFields(
+		data,
+		&m.Address,
+		&m.ReceiveCounter,
+		&m.ErrorCounter,
+		&m.Reserved1,
+		&m.Options,
+		&m.Speed,
+		&m.QoS,
+		&m.Reserved2,
+	)
+}
+
+func (m *RailComData) EncapType() uint16 {
+	return LAN_RAILCOM_DATACHANGED
+}
+
+// HasSpeed reports whether the decoder sent a speed report.
+func (m *RailComData) HasSpeed() bool {
+	return m.Options.Has(RC_SPEED1) || m.Options.Has(RC_SPEED2)
+}
+
+// HasQoS reports whether the decoder sent a quality of service report.
+func (m *RailComData) HasQoS() bool {
+	return m.Options.Has(RC_QOS)
+}
+
+// ---------- Correlatable interface ----------
+
+func (m *RailComData) Key() (string, bool) {
+	return railComKey(m.Address)
+}
+
+// RailCom requests the RailCom data of a loco.
+func (nc *Conn) RailCom(ctx context.Context, addr uint16) (*RailComData, error) {
+	if addr < 1 || addr > MaxLocoAddress {
+		return nil, ErrInvalidLocoAddress
+	}
+	resp, err := nc.SendRcv(ctx, &RailComGetData{Address: addr})
+	if err != nil {
+		return nil, err
+	}
+	rc, ok := resp.(*RailComData)
+	if !ok {
+		return nil, fmt.Errorf("z21: unexpected RailCom reply %T", resp)
+	}
+	return rc, nil
+}
+
+// SubscribeRailCom subscribes to RailCom broadcasts for all locos, or
+// only for those controlled by this client.
+func (nc *Conn) SubscribeRailCom(ctx context.Context, all bool) error {
+	if all {
+		return nc.Subscribe(ctx, RAILCOM_UPDATES)
+	}
+	return nc.Subscribe(ctx, RAILCOM_SUB_UPDATES)
+}
+
+// ---------- helpers ----------
+
+func railComKey(addr uint16) (string, bool) {
+	d := []byte{byte(LAN_RAILCOM_DATACHANGED), byte(addr), byte(addr >> 8)}
+	f, err := fingerprint(d)
+	if err != nil {
+		return "", false
+	}
+	return f, true
+}
@@ -0,0 +1,93 @@
+package z21
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestRailComDataLayout(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		want     RailComData
+		hasSpeed bool
+		hasQoS   bool
+	}{
+		{
+			name: "speed and qos",
+			data: []byte{
+				0x39, 0x30, // address 12345
+				0x04, 0x03, 0x02, 0x01, // receive counter
+				0x22, 0x11, // error counter
+				0x00, // reserved
+				0x05, // options
+				0x50, // speed
+				0x07, // qos
+				0x00, // reserved
+			},
+			want: RailComData{
+				Address:        12345,
+				ReceiveCounter: 0x01020304,
+				ErrorCounter:   0x1122,
+				Options:        Mask8(RC_SPEED1 | RC_QOS),
+				Speed:          0x50,
+				QoS:            7,
+			},
+			hasSpeed: true,
+			hasQoS:   true,
+		},
+		{
+			name:     "speed2 only",
+			data:     []byte{0x03, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xFF, 0x00, 0x00},
+			want:     RailComData{Address: 3, ReceiveCounter: 1, Options: Mask8(RC_SPEED2), Speed: 0xFF},
+			hasSpeed: true,
+		},
+		{
+			name: "no reports",
+			data: []byte{0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			want: RailComData{Address: 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got RailComData
+			if err := got.Unpack(tt.data); err != nil {
+				t.Fatalf("Unpack() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Unpack() = %+v, want %+v", got, tt.want)
+			}
+			if got.HasSpeed() != tt.hasSpeed {
+				t.Errorf("HasSpeed() = %v, want %v", got.HasSpeed(), tt.hasSpeed)
+			}
+			if got.HasQoS() != tt.hasQoS {
+				t.Errorf("HasQoS() = %v, want %v", got.HasQoS(), tt.hasQoS)
+			}
+
+			data, err := tt.want.Pack()
+			if err != nil {
+				t.Fatalf("Pack() error = %v", err)
+			}
+			if !bytes.Equal(data, tt.data) {
+				t.Errorf("Pack() = % X, want % X", data, tt.data)
+			}
+			if rt := roundTrip(t, &tt.want); !reflect.DeepEqual(rt, &tt.want) {
+				t.Errorf("round trip = %+v, want %+v", rt, &tt.want)
+			}
+
+			reqKey, _ := (&RailComGetData{Address: tt.want.Address}).Key()
+			if key, _ := got.Key(); key != reqKey {
+				t.Errorf("Key() = %q, want request key %q", key, reqKey)
+			}
+		})
+	}
+}
+
+func TestRailComDataUnpackShort(t *testing.T) {
+	var m RailComData
+	if err := m.Unpack([]byte{0x03, 0x00, 0x01}); err == nil {
+		t.Error("Unpack() of a truncated record succeeded")
+	}
+}
@@ -0,0 +1,238 @@
+package z21
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	RBUS_GROUP_1 uint8 = 0x00 // modules 1-10
+	RBUS_GROUP_2 uint8 = 0x01 // modules 11-20
+
+	RBusModulesPerGroup = 10
+	RBusInputsPerModule = 8
+	RBusModules         = 2 * RBusModulesPerGroup
+
+	RBUS_STOP_PROGRAMMING uint8 = 0x00
+
+	rbusPollInterval = 500 * time.Millisecond
+)
+
+var (
+	ErrInvalidRBusGroup   = errors.New("z21: invalid R-BUS group")
+	ErrInvalidRBusAddress = errors.New("z21: invalid R-BUS module address")
+)
+
+// LAN_RMBUS_GETDATA
+type RBusGetData struct {
+	Group uint8
+}
+
+// ---------- Message interface ----------
+
+func (m *RBusGetData) Pack() ([]byte, error) {
+	if m.Group > RBUS_GROUP_2 {
+		return nil, ErrInvalidRBusGroup
+	}
+	return []byte{m.Group}, nil
+}
+
+func (m *RBusGetData) Unpack(data []byte) error {
+	return UnpackFields(data, &m.Group)
+}
+
+func (m *RBusGetData) EncapType() uint16 {
+	return LAN_RMBUS_GETDATA
+}
+
+// ---------- Correlatable interface ----------
+
+func (m *RBusGetData) Key() (string, bool) {
+	return rbusKey(m.Group)
+}
+
+// LAN_RMBUS_DATACHANGED
+//
+// Each module reports the state of its eight inputs as one bit per
+// input, input 1 being bit 0. Broadcasts are only sent to clients
+// subscribed to FEEDBACK_UPDATES.
+type RBusFeedback struct {
+	Group   uint8                      `json:"group"`
+	Modules [RBusModulesPerGroup]Mask8 `json:"modules"`
+}
+
+// ---------- Message interface ----------
+
+func (m *RBusFeedback) String() string {
+	return "rbus"
+}
+
+func (m *RBusFeedback) Pack() ([]byte, error) {
+	b := make([]byte, 1+RBusModulesPerGroup)
+	b[0] = m.Group
+	for i, mod := range m.Modules {
+		b[1+i] = byte(mod)
+	}
+	return b, nil
+}
+
+func (m *RBusFeedback) Unpack(data []byte) error {
+	return UnpackFields(data, &m.Group, &m.Modules)
+}
+
+func (m *RBusFeedback) EncapType() uint16 {
+	return LAN_RMBUS_DATACHANGED
+}
+
+// Occupied reports the state of an input. Modules are numbered 1-20 and
+// inputs 1-8; ok is false if the module is not part of this group.
+func (m *RBusFeedback) Occupied(module, input int) (occupied bool, ok bool) {
+	i := module - 1 - int(m.Group)*RBusModulesPerGroup
+	if i < 0 || i >= RBusModulesPerGroup || input < 1 || input > RBusInputsPerModule {
+		return false, false
+	}
+	return m.Modules[i].Has(1 << (input - 1)), true
+}
+
+// ---------- Correlatable interface ----------
+
+func (m *RBusFeedback) Key() (string, bool) {
+	return rbusKey(m.Group)
+}
+
+// RBusSnapshot requests the state of all R-BUS modules.
+func (nc *Conn) RBusSnapshot(ctx context.Context) ([]*RBusFeedback, error) {
+	var fbs []*RBusFeedback
+	for _, g := range []uint8{RBUS_GROUP_1, RBUS_GROUP_2} {
+		resp, err := nc.SendRcv(ctx, &RBusGetData{Group: g})
+		if err != nil {
+			return nil, err
+		}
+		fb, ok := resp.(*RBusFeedback)
+		if !ok {
+			return nil, fmt.Errorf("z21: unexpected R-BUS reply %T", resp)
+		}
+		fbs = append(fbs, fb)
+	}
+	return fbs, nil
+}
+
+// SubscribeFeedback subscribes to R-BUS feedback broadcasts, which are
+// then delivered as *RBusFeedback on Events().
+func (nc *Conn) SubscribeFeedback(ctx context.Context) error {
+	return nc.Subscribe(ctx, FEEDBACK_UPDATES)
+}
+
+// LAN_RMBUS_PROGRAMMODULE
+//
+// The Z21 keeps sending the address on the R-BUS until programming is
+// stopped with address RBUS_STOP_PROGRAMMING.
+type RBusProgramModule struct {
+	Address uint8
+}
+
+// ---------- Message interface ----------
+
+func (m *RBusProgramModule) Pack() ([]byte, error) {
+	if int(m.Address) > RBusModules {
+		return nil, ErrInvalidRBusAddress
+	}
+	return []byte{m.Address}, nil
+}
+
+func (m *RBusProgramModule) Unpack(data []byte) error {
+	return nil
+}
+
+func (m *RBusProgramModule) EncapType() uint16 {
+	return LAN_RMBUS_PROGRAMMODULE
+}
+
+// ---------- Correlatable interface ----------
+
+func (m *RBusProgramModule) Key() (string, bool) {
+	return "", false
+}
+
+// ProgramRBusModule assigns an address (1-20) to an R-BUS module. The
+// address is sent for the hold duration, during which the module's
+// programming key must be pressed, and programming is then stopped.
+//
+// To confirm the new address, ProgramRBusModule takes a snapshot of the
+// address's inputs before programming and then polls the R-BUS data
+// until they change, so change the occupancy of one of the module's
+// inputs after programming. It gives up when ctx is done.
+func (nc *Conn) ProgramRBusModule(ctx context.Context, addr uint8, hold time.Duration) error {
+	if addr < 1 || int(addr) > RBusModules {
+		return ErrInvalidRBusAddress
+	}
+
+	before, err := nc.rbusModule(ctx, addr)
+	if err != nil {
+		return err
+	}
+
+	if _, err := nc.SendRcv(ctx, &RBusProgramModule{Address: addr}); err != nil {
+		return err
+	}
+
+	t := time.NewTimer(hold)
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+	t.Stop()
+
+	stop := &RBusProgramModule{Address: RBUS_STOP_PROGRAMMING}
+	if _, err := nc.SendRcv(context.Background(), stop); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(rbusPollInterval)
+	defer ticker.Stop()
+	for {
+		inputs, err := nc.rbusModule(ctx, addr)
+		if err != nil {
+			return err
+		}
+		if inputs != before {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("z21: R-BUS module %d not seen: %w", addr, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// ---------- helpers ----------
+
+// rbusModule returns the inputs of the module with the given address.
+func (nc *Conn) rbusModule(ctx context.Context, addr uint8) (Mask8, error) {
+	group := (addr - 1) / RBusModulesPerGroup
+	resp, err := nc.SendRcv(ctx, &RBusGetData{Group: group})
+	if err != nil {
+		return 0, err
+	}
+	fb, ok := resp.(*RBusFeedback)
+	if !ok {
+		return 0, fmt.Errorf("z21: unexpected R-BUS reply %T", resp)
+	}
+	return fb.Modules[(addr-1)%RBusModulesPerGroup], nil
+}
+
+func rbusKey(group uint8) (string, bool) {
+	d := []byte{byte(LAN_RMBUS_DATACHANGED), group}
+	f, err := fingerprint(d)
+	if err != nil {
+		return "", false
+	}
+	return f, true
+}
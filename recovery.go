@@ -0,0 +1,199 @@
+package z21
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RecoveryConfig configures automatic power restoration after a short
+// circuit. Zero fields take the default given in their comment.
+type RecoveryConfig struct {
+	// Delay before the first attempt, doubled for every further attempt
+	// up to MaxDelay. 2s and 30s by default.
+	Delay    time.Duration
+	MaxDelay time.Duration
+	// MaxRetries is the number of attempts before giving up. 3 by
+	// default.
+	MaxRetries int
+	// Quiet is how long the track must stay free of shorts for the
+	// attempt count to start over. 60s by default.
+	Quiet time.Duration
+}
+
+// RecoveryEvent is either RecoveryAttempt or RecoveryGaveUp.
+type RecoveryEvent interface {
+	EventTime() time.Time
+}
+
+// RecoveryAttempt reports an attempt to switch track power back on. Err
+// is set if the Z21 did not confirm it.
+type RecoveryAttempt struct {
+	Attempt int
+	Delay   time.Duration
+	Time    time.Time
+	Err     error
+}
+
+// RecoveryGaveUp reports that the track kept shorting and power was left
+// off. Recovery resumes after a quiet period or Reset.
+type RecoveryGaveUp struct {
+	Attempts int
+	Time     time.Time
+}
+
+func (e RecoveryAttempt) EventTime() time.Time { return e.Time }
+func (e RecoveryGaveUp) EventTime() time.Time  { return e.Time }
+
+// ShortRecovery switches track power back on after short circuits,
+// reported by LAN_X_BC_TRACK_SHORT_CIRCUIT or the SHORT_CIRCUIT bit of
+// Status and SysData messages on Events().
+type ShortRecovery struct {
+	nc     *Conn
+	cfg    RecoveryConfig
+	cancel func()
+
+	mu        sync.Mutex
+	short     bool
+	pending   bool
+	gaveUp    bool
+	attempts  int
+	lastShort time.Time
+	timer     *time.Timer
+	stopped   bool
+	events    chan RecoveryEvent
+}
+
+// AutoRecover starts restoring track power after short circuits until
+// Stop is called. Short circuit broadcasts need TRACK_UPDATES.
+func (nc *Conn) AutoRecover(cfg RecoveryConfig) *ShortRecovery {
+	defaultDuration(&cfg.Delay, 2*time.Second)
+	defaultDuration(&cfg.MaxDelay, 30*time.Second)
+	defaultInt(&cfg.MaxRetries, 3)
+	defaultDuration(&cfg.Quiet, 60*time.Second)
+
+	r := &ShortRecovery{
+		nc:     nc,
+		cfg:    cfg,
+		events: make(chan RecoveryEvent, defaultEventBufSize),
+	}
+	r.cancel = nc.watchEvents(r.update)
+	return r
+}
+
+// Events returns recovery attempts. Events are dropped if the channel is
+// not drained.
+func (r *ShortRecovery) Events() <-chan RecoveryEvent {
+	return r.events
+}
+
+// Reset clears the attempt count, e.g. after the cause of repeated
+// shorts has been removed.
+func (r *ShortRecovery) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.attempts = 0
+	r.gaveUp = false
+}
+
+// Stop stops watching for short circuits and cancels a scheduled
+// attempt.
+func (r *ShortRecovery) Stop() {
+	r.cancel()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.stopped = true
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+}
+
+// ---------- helpers ----------
+
+func (r *ShortRecovery) update(m Serializable) {
+	switch m := m.(type) {
+	case *TrackShortCircuit:
+		r.shortState(true, true)
+	case *Status:
+		r.shortState(m.Mask.Has(SHORT_CIRCUIT), false)
+	case *SysData:
+		r.shortState(m.CentralState.Has(SHORT_CIRCUIT), false)
+	}
+}
+
+// shortState schedules an attempt on a short circuit broadcast or the
+// rising edge of the status bit, unless one is already pending.
+func (r *ShortRecovery) shortState(short, broadcast bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rising := short && (broadcast || !r.short)
+	r.short = short
+	if !rising || r.pending || r.stopped {
+		return
+	}
+
+	now := time.Now()
+	if now.Sub(r.lastShort) > r.cfg.Quiet {
+		r.attempts = 0
+		r.gaveUp = false
+	}
+	r.lastShort = now
+	if r.gaveUp {
+		return
+	}
+	if r.attempts >= r.cfg.MaxRetries {
+		r.gaveUp = true
+		r.send(RecoveryGaveUp{Attempts: r.attempts, Time: now})
+		return
+	}
+
+	r.attempts++
+	delay := r.cfg.Delay << (r.attempts - 1)
+	if delay > r.cfg.MaxDelay || delay <= 0 {
+		delay = r.cfg.MaxDelay
+	}
+	r.pending = true
+	attempt := r.attempts
+	r.timer = time.AfterFunc(delay, func() {
+		r.restore(attempt, delay)
+	})
+}
+
+// restore switches power back on unless Stop was called since the attempt
+// was scheduled, including after its timer fired.
+func (r *ShortRecovery) restore(attempt int, delay time.Duration) {
+	r.mu.Lock()
+	if r.stopped {
+		r.pending = false
+		r.timer = nil
+		r.mu.Unlock()
+		return
+	}
+	r.mu.Unlock()
+
+	_, err := r.nc.SendRcv(context.Background(), &TrackPower{On: true})
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.pending = false
+	r.timer = nil
+	if r.stopped {
+		return
+	}
+	if err == nil {
+		r.short = false
+	}
+	r.send(RecoveryAttempt{Attempt: attempt, Delay: delay, Time: time.Now(), Err: err})
+}
+
+func (r *ShortRecovery) send(e RecoveryEvent) {
+	select {
+	case r.events <- e:
+	default:
+	}
+}
@@ -0,0 +1,184 @@
+package z21
+
+import (
+	"context"
+	"errors"
+)
+
+const (
+	MinDccRegister uint8 = 1
+	MaxDccRegister uint8 = 8
+
+	MinMmRegister uint8 = 1
+	MaxMmRegister uint8 = 79
+)
+
+var ErrInvalidRegister = errors.New("z21: invalid register")
+
+// LAN_X_DCC_READ_REGISTER
+type DccReadRegister struct {
+	Register uint8
+}
+
+// ---------- Message interface ----------
+
+func (m *DccReadRegister) Pack() ([]byte, error) {
+	if err := checkDccRegister(m.Register); err != nil {
+		return nil, err
+	}
+	return packXBus(LAN_X_DCC_READ_REGISTER, 0x11, m.Register), nil
+}
+
+func (m *DccReadRegister) Unpack(data []byte) error {
+	return nil
+}
+
+func (m *DccReadRegister) EncapType() uint16 {
+	return LAN_X
+}
+
+// ---------- Correlatable interface ----------
+
+func (m *DccReadRegister) Key() (string, bool) {
+	return cvResultKey()
+}
+
+// LAN_X_DCC_WRITE_REGISTER
+type DccWriteRegister struct {
+	Register uint8
+	Value    uint8
+}
+
+// ---------- Message interface ----------
+
+func (m *DccWriteRegister) Pack() ([]byte, error) {
+	if err := checkDccRegister(m.Register); err != nil {
+		return nil, err
+	}
+	return packXBus(LAN_X_23, LAN_X_DCC_WRITE_REGISTER, m.Register, m.Value), nil
+}
+
+func (m *DccWriteRegister) Unpack(data []byte) error {
+	return nil
+}
+
+func (m *DccWriteRegister) EncapType() uint16 {
+	return LAN_X
+}
+
+// ---------- Correlatable interface ----------
+
+func (m *DccWriteRegister) Key() (string, bool) {
+	return cvResultKey()
+}
+
+// LAN_X_MM_WRITE_BYTE
+//
+// Register is numbered from 1 like a CV and sent as register address
+// Register-1.
+type MmWriteByte struct {
+	Register uint8
+	Value    uint8
+}
+
+// ---------- Message interface ----------
+
+func (m *MmWriteByte) Pack() ([]byte, error) {
+	if err := checkMmRegister(m.Register); err != nil {
+		return nil, err
+	}
+	return packXBus(LAN_X_24, LAN_X_MM_WRITE_BYTE, 0x00, m.Register-1, m.Value), nil
+}
+
+func (m *MmWriteByte) Unpack(data []byte) error {
+	return nil
+}
+
+func (m *MmWriteByte) EncapType() uint16 {
+	return LAN_X
+}
+
+// ---------- Correlatable interface ----------
+
+func (m *MmWriteByte) Key() (string, bool) {
+	return cvResultKey()
+}
+
+// ReadRegister reads a register of a decoder on the programming track
+// in register mode.
+func (nc *Conn) ReadRegister(ctx context.Context, reg uint8) (uint8, error) {
+	var v uint8
+	err := nc.programming(ctx, func(CVProgrammer) error {
+		var err error
+		v, err = nc.readRegister(ctx, reg)
+		return err
+	})
+	return v, err
+}
+
+// WriteRegister writes a register of a decoder on the programming track
+// in register mode.
+func (nc *Conn) WriteRegister(ctx context.Context, reg, value uint8) error {
+	return nc.programming(ctx, func(CVProgrammer) error {
+		return nc.writeRegister(ctx, reg, value)
+	})
+}
+
+// MmWrite writes a register (1-79) of a Motorola decoder on the
+// programming track.
+func (nc *Conn) MmWrite(ctx context.Context, reg, value uint8) error {
+	if err := checkMmRegister(reg); err != nil {
+		return err
+	}
+	return nc.programming(ctx, func(CVProgrammer) error {
+		return nc.mmWrite(ctx, reg, value)
+	})
+}
+
+// ---------- helpers ----------
+
+// readRegister, writeRegister and mmWrite expect the caller to hold the
+// programming lock.
+func (nc *Conn) readRegister(ctx context.Context, reg uint8) (uint8, error) {
+	resp, err := nc.SendRcv(ctx, &DccReadRegister{Register: reg})
+	if err != nil {
+		return 0, err
+	}
+	res, err := cvResult(resp)
+	if err != nil {
+		return 0, err
+	}
+	return res.Value, nil
+}
+
+func (nc *Conn) writeRegister(ctx context.Context, reg, value uint8) error {
+	resp, err := nc.SendRcv(ctx, &DccWriteRegister{Register: reg, Value: value})
+	if err != nil {
+		return err
+	}
+	_, err = cvResult(resp)
+	return err
+}
+
+func (nc *Conn) mmWrite(ctx context.Context, reg, value uint8) error {
+	resp, err := nc.SendRcv(ctx, &MmWriteByte{Register: reg, Value: value})
+	if err != nil {
+		return err
+	}
+	_, err = cvResult(resp)
+	return err
+}
+
+func checkDccRegister(reg uint8) error {
+	if reg < MinDccRegister || reg > MaxDccRegister {
+		return ErrInvalidRegister
+	}
+	return nil
+}
+
+func checkMmRegister(reg uint8) error {
+	if reg < MinMmRegister || reg > MaxMmRegister {
+		return ErrInvalidRegister
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package z21
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestRegisterPack(t *testing.T) {
+	tests := []struct {
+		name string
+		m    Serializable
+		want []byte
+		err  error
+	}{
+		{name: "dcc read", m: &DccReadRegister{Register: 1}, want: xbus(0x22, 0x11, 0x01)},
+		{name: "dcc write", m: &DccWriteRegister{Register: 8, Value: 3}, want: xbus(0x23, 0x12, 0x08, 0x03)},
+		{name: "dcc register 0", m: &DccReadRegister{Register: 0}, err: ErrInvalidRegister},
+		{name: "dcc register 9", m: &DccWriteRegister{Register: 9}, err: ErrInvalidRegister},
+		{name: "mm first", m: &MmWriteByte{Register: 1, Value: 80}, want: xbus(0x24, 0xFF, 0x00, 0x00, 0x50)},
+		{name: "mm last", m: &MmWriteByte{Register: 79, Value: 1}, want: xbus(0x24, 0xFF, 0x00, 0x4E, 0x01)},
+		{name: "mm register 0", m: &MmWriteByte{Register: 0}, err: ErrInvalidRegister},
+		{name: "mm register 80", m: &MmWriteByte{Register: 80}, err: ErrInvalidRegister},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.m.Pack()
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Pack() error = %v, want %v", err, tt.err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("Pack() = % X, want % X", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package z21
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var ErrSessionEnded = errors.New("z21: programming session ended")
+
+// ProgrammingSession holds exclusive access to CV programming on a
+// connection. The Z21 answers all programming requests with the same
+// LAN_X_CV_RESULT, so only one may be in flight at a time.
+//
+// Conn methods such as ReadCV and ReadRegister take the same lock for
+// the duration of a single operation, so they block while a session is
+// active; use the session's methods instead. These may be called from
+// several goroutines and run one at a time.
+type ProgrammingSession struct {
+	nc      *Conn
+	restore bool
+
+	mu    sync.Mutex // serialises operations
+	ended bool
+}
+
+// BeginProgramming starts a programming session, waiting for any other
+// programming to finish first.
+func (nc *Conn) BeginProgramming(ctx context.Context) (*ProgrammingSession, error) {
+	if err := nc.lockProgramming(ctx); err != nil {
+		return nil, err
+	}
+
+	resp, err := nc.SendRcv(ctx, &Status{})
+	if err != nil {
+		nc.unlockProgramming()
+		return nil, err
+	}
+	st, ok := resp.(*Status)
+	if !ok {
+		nc.unlockProgramming()
+		return nil, fmt.Errorf("z21: unexpected status reply %T", resp)
+	}
+
+	return &ProgrammingSession{
+		nc: nc,
+		restore: !st.Mask.Has(TRACK_VOLTAGE_OFF) &&
+			!st.Mask.Has(EMERGENCY_STOP) &&
+			!st.Mask.Has(PROGRAMMING_MODE_ACTIVE),
+	}, nil
+}
+
+// End releases the session. If track power was on when the session
+// began, it is switched on again, which also leaves programming mode.
+func (s *ProgrammingSession) End(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ended {
+		return nil
+	}
+	s.ended = true
+	defer s.nc.unlockProgramming()
+
+	if !s.restore {
+		return nil
+	}
+	_, err := s.nc.SendRcv(ctx, &TrackPower{On: true})
+	return err
+}
+
+// ReadCV reads a CV of the decoder on the programming track.
+func (s *ProgrammingSession) ReadCV(ctx context.Context, cv uint16) (uint8, error) {
+	var v uint8
+	err := s.run(func(p CVProgrammer) error {
+		var err error
+		v, err = p.ReadCV(ctx, cv)
+		return err
+	})
+	return v, err
+}
+
+// WriteCV writes a CV of the decoder on the programming track.
+func (s *ProgrammingSession) WriteCV(ctx context.Context, cv uint16, value uint8) error {
+	return s.run(func(p CVProgrammer) error {
+		return p.WriteCV(ctx, cv, value)
+	})
+}
+
+func (s *ProgrammingSession) IdentifyDecoder(ctx context.Context) (*DecoderInfo, error) {
+	var info *DecoderInfo
+	err := s.run(func(p CVProgrammer) error {
+		var err error
+		info, err = identifyDecoder(ctx, p)
+		return err
+	})
+	return info, err
+}
+
+func (s *ProgrammingSession) ReadCV29(ctx context.Context) (CV29, error) {
+	var c CV29
+	err := s.run(func(p CVProgrammer) error {
+		var err error
+		c, err = readCV29(ctx, p)
+		return err
+	})
+	return c, err
+}
+
+func (s *ProgrammingSession) WriteCV29(ctx context.Context, c CV29) error {
+	return s.run(func(p CVProgrammer) error {
+		return writeCV29(ctx, p, c)
+	})
+}
+
+func (s *ProgrammingSession) ProgramAddress(ctx context.Context, addr uint16) error {
+	return s.run(func(p CVProgrammer) error {
+		return programAddress(ctx, p, addr)
+	})
+}
+
+// ReadRegister reads a register in register mode.
+func (s *ProgrammingSession) ReadRegister(ctx context.Context, reg uint8) (uint8, error) {
+	var v uint8
+	err := s.run(func(CVProgrammer) error {
+		var err error
+		v, err = s.nc.readRegister(ctx, reg)
+		return err
+	})
+	return v, err
+}
+
+// WriteRegister writes a register in register mode.
+func (s *ProgrammingSession) WriteRegister(ctx context.Context, reg, value uint8) error {
+	return s.run(func(CVProgrammer) error {
+		return s.nc.writeRegister(ctx, reg, value)
+	})
+}
+
+// MmWrite writes a register (1-79) of a Motorola decoder.
+func (s *ProgrammingSession) MmWrite(ctx context.Context, reg, value uint8) error {
+	if err := checkMmRegister(reg); err != nil {
+		return err
+	}
+	return s.run(func(CVProgrammer) error {
+		return s.nc.mmWrite(ctx, reg, value)
+	})
+}
+
+// Pom returns a programmer for the loco decoder with the given address
+// on the main track that runs within the session.
+func (s *ProgrammingSession) Pom(addr uint16) CVProgrammer {
+	return &sessionPom{s: s, addr: addr}
+}
+
+// InProgrammingMode reports whether the Z21 last announced programming
+// mode, either by LAN_X_BC_PROGRAMMING_MODE or in its status.
+func (nc *Conn) InProgrammingMode() bool {
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+
+	return nc.progMode
+}
+
+// ---------- helpers ----------
+
+type sessionPom struct {
+	s    *ProgrammingSession
+	addr uint16
+}
+
+func (p *sessionPom) ReadCV(ctx context.Context, cv uint16) (uint8, error) {
+	var v uint8
+	err := p.s.run(func(CVProgrammer) error {
+		var err error
+		v, err = p.s.nc.pomRead(ctx, &PomReadByte{Address: p.addr, CV: cv}, cv)
+		return err
+	})
+	return v, err
+}
+
+func (p *sessionPom) WriteCV(ctx context.Context, cv uint16, value uint8) error {
+	return p.s.run(func(CVProgrammer) error {
+		_, err := p.s.nc.SendRcv(ctx, &PomWriteByte{Address: p.addr, CV: cv, Value: value})
+		return err
+	})
+}
+
+// trackProgrammer programs the decoder on the programming track; callers
+// must hold the programming lock.
+type trackProgrammer struct {
+	nc *Conn
+}
+
+func (p trackProgrammer) ReadCV(ctx context.Context, cv uint16) (uint8, error) {
+	return p.nc.readCV(ctx, cv)
+}
+
+func (p trackProgrammer) WriteCV(ctx context.Context, cv uint16, value uint8) error {
+	return p.nc.writeCV(ctx, cv, value)
+}
+
+// run runs fn with the session's programmer, one operation at a time, so
+// that goroutines sharing the session cannot mix up replies.
+func (s *ProgrammingSession) run(fn func(p CVProgrammer) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ended {
+		return ErrSessionEnded
+	}
+	return fn(trackProgrammer{nc: s.nc})
+}
+
+// programming runs fn while holding the programming lock.
+func (nc *Conn) programming(ctx context.Context, fn func(p CVProgrammer) error) error {
+	if err := nc.lockProgramming(ctx); err != nil {
+		return err
+	}
+	defer nc.unlockProgramming()
+
+	return fn(trackProgrammer{nc: nc})
+}
+
+// program sends a single programming request while holding the
+// programming lock.
+func (nc *Conn) program(ctx context.Context, m Serializable) (Serializable, error) {
+	var resp Serializable
+	err := nc.programming(ctx, func(CVProgrammer) error {
+		var err error
+		resp, err = nc.SendRcv(ctx, m)
+		return err
+	})
+	return resp, err
+}
+
+func (nc *Conn) lockProgramming(ctx context.Context) error {
+	select {
+	case nc.prog <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (nc *Conn) unlockProgramming() {
+	<-nc.prog
+}
+
+// trackProgrammingMode follows programming mode announcements; callers
+// must hold nc.mu.
+func (nc *Conn) trackProgrammingMode(m Serializable) {
+	switch m := m.(type) {
+	case *ProgrammingMode:
+		nc.progMode = true
+	case *TrackPower:
+		nc.progMode = false
+	case *Status:
+		nc.progMode = m.Mask.Has(PROGRAMMING_MODE_ACTIVE)
+	}
+}
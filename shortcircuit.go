@@ -0,0 +1,31 @@
+package z21
+
+// LAN_X_BC_TRACK_SHORT_CIRCUIT
+//
+// Broadcast to clients subscribed to TRACK_UPDATES when the Z21 has
+// switched off track power because of a short circuit.
+type TrackShortCircuit struct{}
+
+// ---------- Message interface ----------
+
+func (m *TrackShortCircuit) String() string {
+	return "short circuit"
+}
+
+func (m *TrackShortCircuit) Pack() ([]byte, error) {
+	return packXBus(LAN_X_61, LAN_X_BC_TRACK_SHORT_CIRCUIT), nil
+}
+
+func (m *TrackShortCircuit) Unpack(data []byte) error {
+	return nil
+}
+
+func (m *TrackShortCircuit) EncapType() uint16 {
+	return LAN_X
+}
+
+// ---------- Correlatable interface ----------
+
+func (m *TrackShortCircuit) Key() (string, bool) {
+	return "", false
+}
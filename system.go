@@ -1,10 +1,72 @@
 package z21
 
 import (
+	"context"
 	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrSysDataReply            = errors.New("z21: SysData is a reply, send SysDataGet")
+	ErrSystemUpdatesSubscribed = errors.New("z21: SYSTEM_UPDATES subscribed, system state arrives as broadcasts")
+)
+
+const (
+	// CentralStateEx
+	HIGH_TEMPERATURE       uint8 = 0x01 // bit 0
+	POWER_LOST             uint8 = 0x02 // bit 1
+	SHORT_CIRCUIT_EXTERNAL uint8 = 0x04 // bit 2
+	SHORT_CIRCUIT_INTERNAL uint8 = 0x08 // bit 3
+	RCN213_MODE            uint8 = 0x20 // bit 5
+
+	// Capabilities
+	CAP_DCC               uint8 = 0x01 // bit 0
+	CAP_MM                uint8 = 0x02 // bit 1
+	CAP_RAILCOM           uint8 = 0x08 // bit 3
+	CAP_LOCO_CMDS         uint8 = 0x10 // bit 4
+	CAP_ACCESSORY_CMDS    uint8 = 0x20 // bit 5
+	CAP_DETECTOR_CMDS     uint8 = 0x40 // bit 6
+	CAP_NEEDS_UNLOCK_CODE uint8 = 0x80 // bit 7
 )
 
 // LAN_SYSTEMSTATE_GETDATA
+//
+// The Z21 answers with a SysData. Its reply cannot be told apart from a
+// SYSTEM_UPDATES broadcast, so sending it fails with
+// ErrSystemUpdatesSubscribed while this client is subscribed; SystemState
+// handles both cases.
+type SysDataGet struct{}
+
+// ---------- Message interface ----------
+
+func (m *SysDataGet) Pack() ([]byte, error) {
+	return []byte{}, nil
+}
+
+func (m *SysDataGet) Unpack(data []byte) error {
+	return nil
+}
+
+func (m *SysDataGet) EncapType() uint16 {
+	return LAN_SYSTEMSTATE_GETDATA
+}
+
+// ---------- Correlatable interface ----------
+
+func (m *SysDataGet) Key() (string, bool) {
+	return sysDataKey()
+}
+
+// LAN_SYSTEMSTATE_DATACHANGED
+//
+// Sent in reply to SysDataGet and as a broadcast to clients subscribed
+// to SYSTEM_UPDATES. While subscribed, every SysData is a broadcast and
+// is delivered on Events(); otherwise it answers the pending SysDataGet.
+//
+// SysData used to be sent as the request itself. It now only describes
+// the reply and Pack fails with ErrSysDataReply.
 type SysData struct {
 	MainCurrent         uint16 `json:"main_current"`
 	ProgCurrent         uint16 `json:"program_current"`
@@ -25,7 +87,7 @@ func (m *SysData) String() string {
 }
 
 func (m *SysData) Pack() ([]byte, error) {
-	return []byte{}, nil
+	return nil, ErrSysDataReply
 }
 
 func (m *SysData) Unpack(data []byte) error {
@@ -39,7 +101,139 @@ func (m *SysData) Unpack(data []byte) error {
 }
 
 func (m *SysData) EncapType() uint16 {
-	return LAN_SYSTEMSTATE_GETDATA
+	return LAN_SYSTEMSTATE_DATACHANGED
+}
+
+// SystemState returns the current system state. Without SYSTEM_UPDATES
+// it is requested; with it, the next broadcast is awaited, for at most
+// the connection's timeout.
+func (nc *Conn) SystemState(ctx context.Context) (*SysData, error) {
+	subscribed, err := nc.systemUpdates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if subscribed {
+		return nc.nextSysData(ctx)
+	}
+	return nc.requestSysData(ctx)
+}
+
+// PollSystemState requests the system state every interval and delivers
+// it on Events(), as if SYSTEM_UPDATES broadcasts were subscribed. Polls
+// are skipped while they are. It returns when ctx is done.
+func (nc *Conn) PollSystemState(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		sd, err := nc.pollSystemState(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			nc.Opts.Logger.Warn().Err(err).Msg("system state poll failed")
+			continue
+		}
+		if sd != nil {
+			nc.dispatch(sd)
+		}
+	}
+}
+
+// MainCurrentMA returns the current on the main track in mA.
+func (m *SysData) MainCurrentMA() int {
+	return int(int16(m.MainCurrent))
+}
+
+// ProgCurrentMA returns the current on the programming track in mA.
+func (m *SysData) ProgCurrentMA() int {
+	return int(int16(m.ProgCurrent))
+}
+
+// FilteredMainCurrentMA returns the smoothed main track current in mA.
+func (m *SysData) FilteredMainCurrentMA() int {
+	return int(int16(m.FilteredMainCurrent))
+}
+
+// TemperatureC returns the internal temperature in °C.
+func (m *SysData) TemperatureC() int {
+	return int(int16(m.Temperature))
+}
+
+// SupplyVoltageMV returns the supply voltage in mV.
+func (m *SysData) SupplyVoltageMV() int {
+	return int(m.SupplyVoltage)
+}
+
+// VccVoltageMV returns the internal track voltage in mV.
+func (m *SysData) VccVoltageMV() int {
+	return int(m.VccVoltage)
+}
+
+func (m *SysData) HighTemperature() bool {
+	return m.CentralStateEx.Has(HIGH_TEMPERATURE)
+}
+
+// PowerLost reports whether the supply voltage is too low.
+func (m *SysData) PowerLost() bool {
+	return m.CentralStateEx.Has(POWER_LOST)
+}
+
+func (m *SysData) ShortCircuitExternal() bool {
+	return m.CentralStateEx.Has(SHORT_CIRCUIT_EXTERNAL)
+}
+
+func (m *SysData) ShortCircuitInternal() bool {
+	return m.CentralStateEx.Has(SHORT_CIRCUIT_INTERNAL)
+}
+
+// RCN213 reports whether turnout addressing follows RCN-213.
+func (m *SysData) RCN213() bool {
+	return m.CentralStateEx.Has(RCN213_MODE)
+}
+
+// HasCapabilities reports whether the capabilities are known. Firmware
+// before 1.42 leaves them zero.
+func (m *SysData) HasCapabilities() bool {
+	return m.Capabilities != 0
+}
+
+func (m *SysData) SupportsDCC() bool {
+	return m.Capabilities.Has(CAP_DCC)
+}
+
+func (m *SysData) SupportsMM() bool {
+	return m.Capabilities.Has(CAP_MM)
+}
+
+func (m *SysData) SupportsRailCom() bool {
+	return m.Capabilities.Has(CAP_RAILCOM)
+}
+
+// SupportsLocoCmds reports whether loco commands are accepted.
+func (m *SysData) SupportsLocoCmds() bool {
+	return m.Capabilities.Has(CAP_LOCO_CMDS)
+}
+
+// SupportsAccessoryCmds reports whether accessory commands are accepted.
+func (m *SysData) SupportsAccessoryCmds() bool {
+	return m.Capabilities.Has(CAP_ACCESSORY_CMDS)
+}
+
+// SupportsDetectorCmds reports whether detector commands are accepted.
+func (m *SysData) SupportsDetectorCmds() bool {
+	return m.Capabilities.Has(CAP_DETECTOR_CMDS)
+}
+
+// NeedsUnlockCode reports whether the device needs an unlock code, e.g.
+// a locked z21start.
+func (m *SysData) NeedsUnlockCode() bool {
+	return m.Capabilities.Has(CAP_NEEDS_UNLOCK_CODE)
 }
 
 // ---------- helpers ----------
@@ -57,9 +251,68 @@ func (m *SysData) decodeState(state []byte) {
 	m.Capabilities = Mask8(state[15])
 }
 
-// ---------- Correlatable interface ----------
+// pollSystemState requests the system state unless SYSTEM_UPDATES
+// broadcasts are subscribed, in which case it returns nil.
+func (nc *Conn) pollSystemState(ctx context.Context) (*SysData, error) {
+	subscribed, err := nc.systemUpdates(ctx)
+	if err != nil || subscribed {
+		return nil, err
+	}
+	return nc.requestSysData(ctx)
+}
 
-func (m *SysData) Key() (string, bool) {
+// systemUpdates queries whether SYSTEM_UPDATES is subscribed.
+func (nc *Conn) systemUpdates(ctx context.Context) (bool, error) {
+	resp, err := nc.SendRcv(ctx, &SubscribedBroadcastFlags{})
+	if err != nil {
+		return false, err
+	}
+	flags, ok := resp.(*SubscribedBroadcastFlags)
+	if !ok {
+		return false, fmt.Errorf("z21: unexpected broadcast flags reply %T", resp)
+	}
+	return flags.Flags.Has(SYSTEM_UPDATES), nil
+}
+
+func (nc *Conn) requestSysData(ctx context.Context) (*SysData, error) {
+	resp, err := nc.SendRcv(ctx, &SysDataGet{})
+	if err != nil {
+		return nil, err
+	}
+	sd, ok := resp.(*SysData)
+	if !ok {
+		return nil, fmt.Errorf("z21: unexpected system state reply %T", resp)
+	}
+	return sd, nil
+}
+
+// nextSysData waits for the next SYSTEM_UPDATES broadcast.
+func (nc *Conn) nextSysData(ctx context.Context) (*SysData, error) {
+	ch := make(chan *SysData, 1)
+	cancel := nc.watch(func(m Serializable) {
+		if sd, ok := m.(*SysData); ok {
+			select {
+			case ch <- sd:
+			default:
+			}
+		}
+	})
+	defer cancel()
+
+	timer := time.NewTimer(nc.Opts.Timeout)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timer.C:
+		return nil, ErrTimeout
+	case sd := <-ch:
+		return sd, nil
+	}
+}
+
+func sysDataKey() (string, bool) {
 	d := []byte{byte(LAN_SYSTEMSTATE_DATACHANGED)}
 	f, err := fingerprint(d)
 	if err != nil {
@@ -67,3 +320,9 @@ func (m *SysData) Key() (string, bool) {
 	}
 	return f, true
 }
+
+// ---------- Correlatable interface ----------
+
+func (m *SysData) Key() (string, bool) {
+	return sysDataKey()
+}
@@ -0,0 +1,363 @@
+package z21
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const defaultTrainHistory = 32
+
+// TrackerConfig configures a TrainTracker.
+type TrackerConfig struct {
+	// Timeout drops a loco from a block if no detector has reported it
+	// there for this long. Zero keeps sightings until the loco leaves.
+	Timeout time.Duration
+	// MaxBlocks is the number of blocks a loco may be in at once, e.g. 2
+	// for a train spanning a block boundary. When exceeded, the loco
+	// leaves the block it was first seen in. Zero means 2.
+	MaxBlocks int
+	// History is the number of movements kept per loco. Zero means 32.
+	History int
+}
+
+// TrainEvent is either TrainEnteredBlock or TrainLeftBlock.
+type TrainEvent interface {
+	LocoAddress() uint16
+}
+
+type TrainEnteredBlock struct {
+	Loco  uint16
+	Block string
+	Time  time.Time
+}
+
+type TrainLeftBlock struct {
+	Loco  uint16
+	Block string
+	Time  time.Time
+}
+
+func (e TrainEnteredBlock) LocoAddress() uint16 { return e.Loco }
+func (e TrainLeftBlock) LocoAddress() uint16    { return e.Loco }
+
+// TrainTracker maintains which loco is in which block of an Occupancy
+// from the loco addresses reported by CAN RailCom detectors, LocoNet
+// transponders and Lissy sensors. A loco leaves a block when no sensor
+// of the block reports it any more, when the block becomes free, on
+// timeout or when it is seen in too many blocks.
+type TrainTracker struct {
+	occ *Occupancy
+	cfg TrackerConfig
+
+	mu      sync.Mutex
+	seen    map[Sensor]map[uint16]time.Time
+	canLoco map[Sensor]map[uint8][]uint16
+	blocks  map[string]map[uint16]time.Time
+	history map[uint16][]TrainEvent
+	events  chan TrainEvent
+}
+
+func NewTrainTracker(o *Occupancy, cfg TrackerConfig) *TrainTracker {
+	if cfg.MaxBlocks <= 0 {
+		cfg.MaxBlocks = 2
+	}
+	if cfg.History <= 0 {
+		cfg.History = defaultTrainHistory
+	}
+	return &TrainTracker{
+		occ:     o,
+		cfg:     cfg,
+		seen:    make(map[Sensor]map[uint16]time.Time),
+		canLoco: make(map[Sensor]map[uint8][]uint16),
+		blocks:  make(map[string]map[uint16]time.Time),
+		history: make(map[uint16][]TrainEvent),
+		events:  make(chan TrainEvent, defaultEventBufSize),
+	}
+}
+
+// Events returns train movements. Events are dropped if the channel is
+// not drained.
+func (t *TrainTracker) Events() <-chan TrainEvent {
+	return t.events
+}
+
+// Update applies a detector message. Occupancy messages are passed on to
+// the Occupancy first, so feeding both from the same stream is safe.
+func (t *TrainTracker) Update(m Serializable) {
+	t.occ.Update(m)
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch m := m.(type) {
+	case *CanRailCom:
+		s := CanSensor(m.NetworkID, m.Address, m.Port)
+		types, ok := t.canLoco[s]
+		if !ok {
+			types = make(map[uint8][]uint16)
+			t.canLoco[s] = types
+		}
+		types[m.Type] = types[m.Type][:0]
+		for _, l := range m.Locos {
+			types[m.Type] = append(types[m.Type], l.Address)
+		}
+		// Only the reported type is fresh; the others keep their time.
+		prev := t.seen[s]
+		locos := make(map[uint16]time.Time)
+		for typ, addrs := range types {
+			for _, a := range addrs {
+				at := now
+				if typ != m.Type {
+					at = prev[a]
+				}
+				if at.After(locos[a]) {
+					locos[a] = at
+				}
+			}
+		}
+		t.seen[s] = locos
+		t.refreshSensor(s, now)
+	case *LocoNetTransponder:
+		s := LocoNetSensor(m.Address)
+		if m.Entered {
+			t.sensorLocos(s)[m.Loco] = now
+		} else {
+			delete(t.sensorLocos(s), m.Loco)
+		}
+		t.refreshSensor(s, now)
+	case *LissyLoco:
+		s := LocoNetSensor(m.Address)
+		t.seen[s] = map[uint16]time.Time{m.Loco: now}
+		t.refreshSensor(s, now)
+	case *RBusFeedback, *CanOccupancy, *LocoNetOccupancy:
+		for _, b := range t.occ.Blocks() {
+			if len(t.blocks[b]) > 0 && !t.occ.Occupied(b) {
+				t.clearBlock(b)
+				t.refreshBlock(b, now)
+			}
+		}
+	}
+}
+
+// Expire drops sightings older than the configured timeout. It is called
+// periodically by TrackTrains.
+func (t *TrainTracker) Expire(now time.Time) {
+	if t.cfg.Timeout <= 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for s, locos := range t.seen {
+		changed := false
+		for l, at := range locos {
+			if now.Sub(at) > t.cfg.Timeout {
+				t.forget(s, l)
+				changed = true
+			}
+		}
+		if changed {
+			t.refreshSensor(s, now)
+		}
+	}
+}
+
+// Where returns the blocks a loco is in.
+func (t *TrainTracker) Where(loco uint16) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var blocks []string
+	for b, locos := range t.blocks {
+		if _, ok := locos[loco]; ok {
+			blocks = append(blocks, b)
+		}
+	}
+	sort.Strings(blocks)
+	return blocks
+}
+
+// Snapshot returns the locos in each block.
+func (t *TrainTracker) Snapshot() map[string][]uint16 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	snap := make(map[string][]uint16, len(t.blocks))
+	for b, locos := range t.blocks {
+		if len(locos) == 0 {
+			continue
+		}
+		for l := range locos {
+			snap[b] = append(snap[b], l)
+		}
+		sort.Slice(snap[b], func(i, j int) bool { return snap[b][i] < snap[b][j] })
+	}
+	return snap
+}
+
+// History returns the most recent movements of a loco, oldest first.
+func (t *TrainTracker) History(loco uint16) []TrainEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]TrainEvent(nil), t.history[loco]...)
+}
+
+// TrackTrains feeds all detector messages delivered on Events() into t
+// until the returned cancel function is called.
+func (nc *Conn) TrackTrains(t *TrainTracker) (cancel func()) {
+	stop := nc.watchEvents(t.Update)
+	if t.cfg.Timeout <= 0 {
+		return stop
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(t.cfg.Timeout / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				t.Expire(now)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			stop()
+			close(done)
+		})
+	}
+}
+
+// ---------- helpers ----------
+
+func (t *TrainTracker) sensorLocos(s Sensor) map[uint16]time.Time {
+	locos, ok := t.seen[s]
+	if !ok {
+		locos = make(map[uint16]time.Time)
+		t.seen[s] = locos
+	}
+	return locos
+}
+
+func (t *TrainTracker) refreshSensor(s Sensor, now time.Time) {
+	for _, b := range t.occ.sensorBlocks(s) {
+		t.refreshBlock(b, now)
+	}
+}
+
+// refreshBlock recomputes the locos of a block from its sensors and
+// emits the differences.
+func (t *TrainTracker) refreshBlock(b string, now time.Time) {
+	present := make(map[uint16]time.Time)
+	for _, s := range t.occ.blockSensors(b) {
+		for l, at := range t.seen[s] {
+			if at.After(present[l]) {
+				present[l] = at
+			}
+		}
+	}
+
+	cur, ok := t.blocks[b]
+	if !ok {
+		cur = make(map[uint16]time.Time)
+		t.blocks[b] = cur
+	}
+	for l := range cur {
+		if _, ok := present[l]; !ok {
+			delete(cur, l)
+			t.record(TrainLeftBlock{Loco: l, Block: b, Time: now})
+		}
+	}
+	var entered []uint16
+	for l, at := range present {
+		if _, ok := cur[l]; !ok {
+			entered = append(entered, l)
+		}
+		cur[l] = at
+	}
+	for _, l := range entered {
+		t.record(TrainEnteredBlock{Loco: l, Block: b, Time: now})
+		t.resolve(l, now)
+	}
+}
+
+// resolve makes a loco leave its oldest blocks while it is in more than
+// MaxBlocks. Sensors shared with other blocks keep their sightings, so
+// the loco stays in those blocks.
+func (t *TrainTracker) resolve(loco uint16, now time.Time) {
+	for {
+		var oldest string
+		var oldestAt time.Time
+		n := 0
+		for b, locos := range t.blocks {
+			at, ok := locos[loco]
+			if !ok {
+				continue
+			}
+			n++
+			if oldest == "" || at.Before(oldestAt) {
+				oldest, oldestAt = b, at
+			}
+		}
+		if n <= t.cfg.MaxBlocks {
+			return
+		}
+		for _, s := range t.occ.blockSensors(oldest) {
+			if blocks := t.occ.sensorBlocks(s); len(blocks) == 1 && blocks[0] == oldest {
+				t.forget(s, loco)
+			}
+		}
+		t.refreshBlock(oldest, now)
+		if _, ok := t.blocks[oldest][loco]; ok {
+			// Still reported by a shared sensor; leave the block until
+			// that sensor reports the loco again.
+			delete(t.blocks[oldest], loco)
+			t.record(TrainLeftBlock{Loco: loco, Block: oldest, Time: now})
+		}
+	}
+}
+
+// forget removes a loco from a sensor, including the CAN RailCom lists
+// its sightings are rebuilt from.
+func (t *TrainTracker) forget(s Sensor, loco uint16) {
+	delete(t.seen[s], loco)
+	for typ, addrs := range t.canLoco[s] {
+		kept := addrs[:0]
+		for _, a := range addrs {
+			if a != loco {
+				kept = append(kept, a)
+			}
+		}
+		t.canLoco[s][typ] = kept
+	}
+}
+
+func (t *TrainTracker) clearBlock(b string) {
+	for _, s := range t.occ.blockSensors(b) {
+		delete(t.seen, s)
+		delete(t.canLoco, s)
+	}
+}
+
+func (t *TrainTracker) record(e TrainEvent) {
+	l := e.LocoAddress()
+	h := append(t.history[l], e)
+	if len(h) > t.cfg.History {
+		h = h[len(h)-t.cfg.History:]
+	}
+	t.history[l] = h
+
+	select {
+	case t.events <- e:
+	default:
+	}
+}
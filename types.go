@@ -19,6 +19,7 @@ type Detector struct {
 type DetectorPort struct {
 	Index  uint8
 	Status uint16
+	Locos  []RailComLoco
 }
 
 type Mask8 uint8
@@ -0,0 +1,41 @@
+package z21
+
+import "errors"
+
+var ErrUnknownCommand = errors.New("z21: unknown command")
+
+// LAN_X_UNKNOWN_COMMAND
+//
+// Sent in reply to an X-Bus request the Z21 does not understand. The
+// reply does not identify the request, so it is taken to refer to the
+// most recent X-Bus request, which then fails with ErrUnknownCommand.
+// If that request is no longer pending, it is delivered on Events().
+type UnknownCommand struct{}
+
+// ---------- Message interface ----------
+
+func (m *UnknownCommand) String() string {
+	return "unknown command"
+}
+
+func (m *UnknownCommand) Pack() ([]byte, error) {
+	return packXBus(LAN_X_61, LAN_X_UNKNOWN_COMMAND), nil
+}
+
+func (m *UnknownCommand) Unpack(data []byte) error {
+	return nil
+}
+
+func (m *UnknownCommand) EncapType() uint16 {
+	return LAN_X
+}
+
+func (m *UnknownCommand) Reject() error {
+	return ErrUnknownCommand
+}
+
+// ---------- Correlatable interface ----------
+
+func (m *UnknownCommand) Key() (string, bool) {
+	return "", false
+}
@@ -32,6 +32,7 @@ const (
 var (
 	ErrBadPacket         = errors.New("z21: invalid packet")
 	ErrInvalidConnection = errors.New("z21: invalid connection")
+	ErrTimeout           = errors.New("z21: request timeout")
 )
 
 type Option func(*Options) error
@@ -48,6 +49,7 @@ type Options struct {
 	CustomDialer CustomDialer
 	Timeout      time.Duration
 	Logger       zerolog.Logger
+	Debounce     *DebounceConfig
 }
 
 type Response struct {
@@ -70,6 +72,20 @@ type Conn struct {
 	requests map[string]*requestEntry
 	events   chan Serializable
 	done     chan struct{}
+	prog     chan struct{}
+	progMode bool
+	watchers map[int]watcher
+	watchID  int
+	filter   *detectorFilter
+	lastX    string
+	// sysUpdates is set while this client is subscribed to
+	// SYSTEM_UPDATES, whose broadcasts look like SysDataGet replies.
+	sysUpdates bool
+}
+
+type watcher struct {
+	fn     func(Serializable)
+	events bool
 }
 
 type z21Reader struct {
@@ -102,6 +118,15 @@ func SetCustomDialer(dialer CustomDialer) Option {
 	}
 }
 
+// Debounce filters detector messages through cfg before they are
+// delivered on Events().
+func Debounce(cfg DebounceConfig) Option {
+	return func(o *Options) error {
+		o.Debounce = &cfg
+		return nil
+	}
+}
+
 func Verbose(v bool) Option {
 	return func(o *Options) error {
 		o.Verbose = v
@@ -153,6 +178,11 @@ func (o Options) Connect() (*Conn, error) {
 		Opts:     o,
 		requests: make(map[string]*requestEntry),
 		done:     make(chan struct{}),
+		prog:     make(chan struct{}, 1),
+		watchers: make(map[int]watcher),
+	}
+	if o.Debounce != nil {
+		nc.filter = newDetectorFilter(*o.Debounce, nc.emit)
 	}
 
 	nc.newReaderWriter()
@@ -234,8 +264,74 @@ func (nc *Conn) close() {
 	}
 }
 
-func (nc *Conn) removeRequest(key string) {
-	delete(nc.requests, key)
+// watch calls fn for every message received until the returned cancel
+// function is called. fn must not block.
+func (nc *Conn) watch(fn func(Serializable)) (cancel func()) {
+	return nc.addWatcher(watcher{fn: fn})
+}
+
+// watchEvents calls fn for every message delivered on Events() until the
+// returned cancel function is called. fn must not block.
+func (nc *Conn) watchEvents(fn func(Serializable)) (cancel func()) {
+	return nc.addWatcher(watcher{fn: fn, events: true})
+}
+
+func (nc *Conn) addWatcher(w watcher) (cancel func()) {
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+
+	id := nc.watchID
+	nc.watchID++
+	nc.watchers[id] = w
+
+	return func() {
+		nc.mu.Lock()
+		defer nc.mu.Unlock()
+
+		delete(nc.watchers, id)
+	}
+}
+
+// dispatch delivers an uncorrelated message on Events(), through the
+// detector filter if one is configured.
+func (nc *Conn) dispatch(m Serializable) {
+	if nc.filter != nil && nc.filter.handle(m) {
+		return
+	}
+	nc.emit(m)
+}
+
+func (nc *Conn) emit(m Serializable) {
+	select {
+	case nc.events <- m:
+	default:
+		nc.Opts.Logger.Warn().Msgf("dropped event: %s", m)
+	}
+	for _, fn := range nc.watcherFuncs(true) {
+		fn(m)
+	}
+}
+
+func (nc *Conn) watcherFuncs(events bool) []func(Serializable) {
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+
+	fns := make([]func(Serializable), 0, len(nc.watchers))
+	for _, w := range nc.watchers {
+		if w.events == events {
+			fns = append(fns, w.fn)
+		}
+	}
+	return fns
+}
+
+// removeRequest removes entry unless a later request with the same key
+// has replaced it. nc.mu must be held.
+func (nc *Conn) removeRequest(entry *requestEntry) {
+	entry.timer.Stop()
+	if nc.requests[entry.key] == entry {
+		delete(nc.requests, entry.key)
+	}
 }
 
 func (nc *Conn) SendRcv(ctx context.Context, m Serializable) (Serializable, error) {
@@ -247,63 +343,83 @@ func (nc *Conn) SendRcv(ctx context.Context, m Serializable) (Serializable, erro
 		return nil, ErrBadPacket
 	}
 
-	respCh, err := nc.send(m)
+	entry, err := nc.send(m)
 	if err != nil {
 		return nil, err
 	}
 
-	if _, wait := m.Key(); !wait {
+	if entry == nil {
 		return nil, nil
 	}
 
 	select {
 	case <-ctx.Done():
+		nc.mu.Lock()
+		nc.removeRequest(entry)
+		nc.mu.Unlock()
 		return nil, ctx.Err()
-	case resp := <-respCh:
+	case resp := <-entry.response:
 		return resp.Message, resp.Err
 	}
 }
 
-func (nc *Conn) send(m Serializable) (<-chan Response, error) {
+// send writes m and, unless it is fire-and-forget, returns the entry its
+// reply is delivered to.
+func (nc *Conn) send(m Serializable) (*requestEntry, error) {
 	w := nc.bw.w
 	log := nc.Opts.Logger
 
 	respCh := make(chan Response, 1)
 
+	frame, err := WrapMessage(m)
+	if err != nil {
+		return nil, err
+	}
+
+	bytes, err := frame.Pack()
+	if err != nil {
+		return nil, err
+	}
+
 	key, ok := m.Key()
 	if !ok {
 		log.Debug().
 			Msg("fire and forget: response tracking disabled")
 	}
-	if key != "" {
-		nc.mu.Lock()
-		nc.requests[key] = &requestEntry{
-			key:      key,
-			response: respCh,
-			timer: time.AfterFunc(nc.Opts.Timeout, func() {
-				select {
-				case respCh <- Response{Err: fmt.Errorf("request timeout")}:
-				default:
-				}
-
-				nc.removeRequest(key)
-			}),
-		}
+	var entry *requestEntry
+	nc.mu.Lock()
+	if _, ok := m.(*SysDataGet); ok && nc.sysUpdates {
 		nc.mu.Unlock()
+		return nil, ErrSystemUpdatesSubscribed
 	}
-
-	frame, err := WrapMessage(m)
-	if err != nil {
-		return nil, err
+	nc.trackBroadcastFlags(m)
+	if m.EncapType() == LAN_X {
+		// LAN_X_UNKNOWN_COMMAND refers to the last X-Bus request.
+		nc.lastX = key
 	}
+	if key != "" {
+		entry = &requestEntry{key: key, response: respCh}
+		entry.timer = time.AfterFunc(nc.Opts.Timeout, func() {
+			select {
+			case respCh <- Response{Err: ErrTimeout}:
+			default:
+			}
 
-	bytes, err := frame.Pack()
-	if err != nil {
-		return nil, err
+			nc.mu.Lock()
+			nc.removeRequest(entry)
+			nc.mu.Unlock()
+		})
+		nc.requests[key] = entry
 	}
+	nc.mu.Unlock()
 
 	_, err = w.Write(bytes)
 	if err != nil {
+		if entry != nil {
+			nc.mu.Lock()
+			nc.removeRequest(entry)
+			nc.mu.Unlock()
+		}
 		return nil, ErrBadPacket
 	}
 
@@ -313,10 +429,7 @@ func (nc *Conn) send(m Serializable) (<-chan Response, error) {
 	log.Debug().
 		Msgf("hexdump:\n%s", strings.TrimRight(hex.Dump(bytes), "\n"))
 
-	if _, ok := m.Key(); !ok {
-		close(respCh)
-	}
-	return respCh, nil
+	return entry, nil
 }
 
 func (nc *Conn) Listen() {
@@ -358,23 +471,36 @@ func (nc *Conn) Listen() {
 			}
 
 			nc.mu.Lock()
+			nc.trackProgrammingMode(m)
 			key, _ := m.Key()
-			if entry, ok := nc.requests[key]; ok {
-				entry.timer.Stop()
-				select {
-				case entry.response <- Response{Message: m}:
-				default:
+			if _, ok := m.(*UnknownCommand); ok {
+				key, nc.lastX = nc.lastX, ""
+			}
+			nc.trackBroadcastFlags(m)
+			if _, ok := m.(*SysData); ok && nc.sysUpdates {
+				key = ""
+			}
+			entry, matched := nc.requests[key]
+			if matched {
+				nc.removeRequest(entry)
+				resp := Response{Message: m}
+				if r, ok := m.(Rejection); ok {
+					resp.Err = r.Reject()
 				}
-				delete(nc.requests, key)
-			} else {
 				select {
-				case nc.events <- m:
+				case entry.response <- resp:
 				default:
-					log.Warn().Msgf("dropped event: %s", m)
 				}
 			}
 			nc.mu.Unlock()
 
+			for _, fn := range nc.watcherFuncs(false) {
+				fn(m)
+			}
+			if !matched {
+				nc.dispatch(m)
+			}
+
 			log.Debug().
 				Str("fingerprint", key).
 				Msgf("[RX] %s", frame.Name())
@@ -0,0 +1,92 @@
+package z21
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// roundTrip packs m into a frame and decodes it again the way Listen
+// does.
+func roundTrip(t *testing.T, m Serializable) Serializable {
+	t.Helper()
+
+	frame, err := WrapMessage(m)
+	if err != nil {
+		t.Fatalf("WrapMessage(%T): %v", m, err)
+	}
+	data, err := frame.Pack()
+	if err != nil {
+		t.Fatalf("Frame.Pack(%T): %v", m, err)
+	}
+	frames, err := ParseFrames(data)
+	if err != nil || len(frames) != 1 {
+		t.Fatalf("ParseFrames(%x) = %v, %v", data, frames, err)
+	}
+	got, err := DecodeFrame(frames[0])
+	if err != nil {
+		t.Fatalf("DecodeFrame(%x): %v", data, err)
+	}
+	if err := got.Unpack(frames[0].Payload); err != nil {
+		t.Fatalf("%T.Unpack(%x): %v", got, frames[0].Payload, err)
+	}
+	return got
+}
+
+// fakeZ21 answers every request with the messages returned by reply.
+type fakeZ21 struct {
+	t     *testing.T
+	reply func(req Frame) []Serializable
+}
+
+func (f *fakeZ21) Dial(network, address string) (net.Conn, error) {
+	client, server := net.Pipe()
+	go f.serve(server)
+	return client, nil
+}
+
+func (f *fakeZ21) serve(c net.Conn) {
+	defer c.Close()
+
+	buf := make([]byte, defaultBufSize)
+	for {
+		n, err := c.Read(buf)
+		if err != nil {
+			return
+		}
+		frames, err := ParseFrames(buf[:n])
+		if err != nil {
+			f.t.Errorf("fake z21: %v", err)
+			return
+		}
+		for _, req := range frames {
+			for _, m := range f.reply(req) {
+				frame, err := WrapMessage(m)
+				if err != nil {
+					f.t.Errorf("fake z21: %v", err)
+					return
+				}
+				data, err := frame.Pack()
+				if err != nil {
+					f.t.Errorf("fake z21: %v", err)
+					return
+				}
+				if _, err := c.Write(data); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// connectFake connects to a fakeZ21 with a short request timeout.
+func connectFake(t *testing.T, reply func(req Frame) []Serializable) *Conn {
+	t.Helper()
+
+	nc, err := Connect("fake", SetCustomDialer(&fakeZ21{t: t, reply: reply}), Timeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(nc.Close)
+	return nc
+}